@@ -0,0 +1,234 @@
+package uncozip
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// https://www.winzip.com/en/support/aes-encryption/
+//
+// The method of an entry encrypted with WinZip AES is 99 and the extra
+// field 0x9901 has the real compression method. The data of the entry is
+// the salt, the password verification value, the encrypted data and the
+// authentication code (HMAC-SHA1 of the encrypted data).
+
+const (
+	WinZipAES = 99
+
+	idWinZipAES = 0x9901
+
+	aesPasswordVerifySize = 2
+	aesAuthCodeSize       = 10
+	aesIterationCount     = 1000
+)
+
+var ErrAuthentication = errors.New("WinZip AES: authentication code mismatch")
+
+type _WinZipAES struct {
+	Version  uint16
+	VendorID [2]byte
+	Strength byte
+	Method   uint16
+}
+
+func (a *_WinZipAES) keySize() int {
+	return 8 + 8*int(a.Strength)
+}
+
+func (a *_WinZipAES) saltSize() int {
+	return a.keySize() / 2
+}
+
+func readWinZipAES(r io.Reader, cz *CorruptedZip) error {
+	var a _WinZipAES
+	if err := binary.Read(r, binary.LittleEndian, &a); err != nil {
+		return fmt.Errorf("WinZip AES extra field broken: %w", err)
+	}
+	if a.Strength < 1 || a.Strength > 3 {
+		return fmt.Errorf("WinZip AES: unsupported strength(%d)", a.Strength)
+	}
+	cz.Debug("  WinZip AES: Version: AE-", a.Version)
+	cz.Debug("  WinZip AES: Strength:", a.keySize()*8)
+	cz.Debug("  WinZip AES: Method:", a.Method)
+	cz.aes = &a
+	return nil
+}
+
+// pbkdf2 is PBKDF2-HMAC-SHA1 (RFC 8018)
+func pbkdf2(password, salt []byte, iter, keyLen int) []byte {
+	prf := hmac.New(sha1.New, password)
+	hashLen := prf.Size()
+	numBlocks := (keyLen + hashLen - 1) / hashLen
+
+	var buf [4]byte
+	dk := make([]byte, 0, numBlocks*hashLen)
+	u := make([]byte, hashLen)
+	for block := 1; block <= numBlocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(buf[:], uint32(block))
+		prf.Write(buf[:4])
+		dk = prf.Sum(dk)
+		t := dk[len(dk)-hashLen:]
+		copy(u, t)
+
+		for n := 2; n <= iter; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = u[:0]
+			u = prf.Sum(u)
+			for i := range u {
+				t[i] ^= u[i]
+			}
+		}
+	}
+	return dk[:keyLen]
+}
+
+// ctrLE is the AES-CTR mode of WinZip whose counter is little-endian and starts from 1.
+type ctrLE struct {
+	block   cipher.Block
+	counter [aes.BlockSize]byte
+	stream  [aes.BlockSize]byte
+	used    int
+}
+
+func newCTRLE(block cipher.Block) *ctrLE {
+	return &ctrLE{block: block, used: aes.BlockSize}
+}
+
+func (c *ctrLE) XORKeyStream(dst, src []byte) {
+	for i, b := range src {
+		if c.used >= aes.BlockSize {
+			for j := range c.counter {
+				c.counter[j]++
+				if c.counter[j] != 0 {
+					break
+				}
+			}
+			c.block.Encrypt(c.stream[:], c.counter[:])
+			c.used = 0
+		}
+		dst[i] = b ^ c.stream[c.used]
+		c.used++
+	}
+}
+
+type aesDecrypter struct {
+	r         io.Reader
+	name      string // for error message
+	info      *_WinZipAES
+	pwdHolder *_PasswordHolder
+	stream    cipher.Stream
+	mac       hash.Hash
+	buffer    []byte
+	held      int
+	ready     bool
+	err       error
+}
+
+func newAESDecrypter(r io.Reader, name string, info *_WinZipAES, pwdHolder *_PasswordHolder) *aesDecrypter {
+	return &aesDecrypter{
+		r:         r,
+		name:      name,
+		info:      info,
+		pwdHolder: pwdHolder,
+		buffer:    make([]byte, 4096+aesAuthCodeSize),
+	}
+}
+
+func (d *aesDecrypter) init() error {
+	header := make([]byte, d.info.saltSize()+aesPasswordVerifySize)
+	if _, err := io.ReadFull(d.r, header); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	salt := header[:d.info.saltSize()]
+	verifier := header[d.info.saltSize():]
+	keySize := d.info.keySize()
+	for i := 0; ; i++ {
+		if i >= 3 {
+			return &ErrPassword{name: d.name}
+		}
+		pwd, err := d.pwdHolder.Ask(d.name, i > 0)
+		if err != nil {
+			return err
+		}
+		key := pbkdf2(pwd, salt, aesIterationCount, keySize*2+aesPasswordVerifySize)
+		if subtle.ConstantTimeCompare(key[keySize*2:], verifier) != 1 {
+			continue
+		}
+		block, err := aes.NewCipher(key[:keySize])
+		if err != nil {
+			return err
+		}
+		d.stream = newCTRLE(block)
+		d.mac = hmac.New(sha1.New, key[keySize:keySize*2])
+		return nil
+	}
+}
+
+// Read decrypts the data holding back the last bytes for the authentication code.
+func (d *aesDecrypter) Read(p []byte) (int, error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+	if !d.ready {
+		if d.err = d.init(); d.err != nil {
+			return 0, d.err
+		}
+		d.ready = true
+	}
+	for {
+		n, err := d.r.Read(d.buffer[d.held:])
+		d.held += n
+		if err == io.EOF {
+			if d.held < aesAuthCodeSize {
+				d.err = io.ErrUnexpectedEOF
+				return 0, d.err
+			}
+			size := d.held - aesAuthCodeSize
+			if size > len(p) {
+				size = len(p)
+			}
+			d.output(p[:size])
+			if d.held > aesAuthCodeSize {
+				return size, nil
+			}
+			if !hmac.Equal(d.mac.Sum(nil)[:aesAuthCodeSize], d.buffer[:aesAuthCodeSize]) {
+				d.err = ErrAuthentication
+			} else {
+				d.err = io.EOF
+			}
+			return size, d.err
+		}
+		if err != nil {
+			d.err = err
+			return 0, err
+		}
+		if size := d.held - aesAuthCodeSize; size > 0 {
+			if size > len(p) {
+				size = len(p)
+			}
+			d.output(p[:size])
+			return size, nil
+		}
+	}
+}
+
+// output decrypts len(p) bytes from the head of the buffer into p.
+func (d *aesDecrypter) output(p []byte) {
+	d.mac.Write(d.buffer[:len(p)])
+	d.stream.XORKeyStream(p, d.buffer[:len(p)])
+	d.held = copy(d.buffer, d.buffer[len(p):d.held])
+}
@@ -0,0 +1,123 @@
+package uncozip
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+)
+
+func encryptWinZipAES(t *testing.T, password, salt, plain []byte, strength byte) []byte {
+	t.Helper()
+	keySize := 8 + 8*int(strength)
+	key := pbkdf2(password, salt, aesIterationCount, keySize*2+aesPasswordVerifySize)
+	block, err := aes.NewCipher(key[:keySize])
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	encrypted := make([]byte, len(plain))
+	newCTRLE(block).XORKeyStream(encrypted, plain)
+	mac := hmac.New(sha1.New, key[keySize:keySize*2])
+	mac.Write(encrypted)
+
+	var buffer bytes.Buffer
+	buffer.Write(salt)
+	buffer.Write(key[keySize*2:])
+	buffer.Write(encrypted)
+	buffer.Write(mac.Sum(nil)[:aesAuthCodeSize])
+	return buffer.Bytes()
+}
+
+func makeAESEntry(name string, method uint16, version uint16, data []byte, crc, size uint32) []byte {
+	var extra bytes.Buffer
+	binary.Write(&extra, binary.LittleEndian, uint16(idWinZipAES))
+	binary.Write(&extra, binary.LittleEndian, uint16(7))
+	binary.Write(&extra, binary.LittleEndian, &_WinZipAES{
+		Version:  version,
+		VendorID: [2]byte{'A', 'E'},
+		Strength: 3,
+		Method:   method,
+	})
+	header := _LocalFileHeader{
+		RequiredVersion:  51,
+		Bits:             bitEncrypted,
+		Method:           WinZipAES,
+		CRC32:            crc,
+		CompressedSize:   uint32(len(data)),
+		UncompressedSize: size,
+		FilenameLength:   uint16(len(name)),
+		ExtendFieldSize:  uint16(extra.Len()),
+	}
+	var buffer bytes.Buffer
+	buffer.Write(sigLocalFileHeader)
+	binary.Write(&buffer, binary.LittleEndian, &header)
+	buffer.WriteString(name)
+	buffer.Write(extra.Bytes())
+	buffer.Write(data)
+	return buffer.Bytes()
+}
+
+func TestPBKDF2(t *testing.T) {
+	// RFC 6070 test vector
+	expect := []byte("\x4b\x00\x79\x01\xb7\x65\x48\x9a\xbe\xad\x49\xd9\x26\xf7\x21\xd0\x65\xa4\x29\xc1")
+	if got := pbkdf2([]byte("password"), []byte("salt"), 4096, 20); !bytes.Equal(got, expect) {
+		t.Fatalf("pbkdf2: expect %X, but %X", expect, got)
+	}
+}
+
+func TestWinZipAES(t *testing.T) {
+	plain := []byte("WinZip AES encryption: AES-256, HMAC-SHA1 and PBKDF2.\n")
+	var compressed bytes.Buffer
+	fw, _ := flate.NewWriter(&compressed, flate.BestCompression)
+	fw.Write(plain)
+	fw.Close()
+
+	salt := []byte("0123456789ABCDEF")
+	data := encryptWinZipAES(t, []byte("secret"), salt, compressed.Bytes(), 3)
+
+	var archive bytes.Buffer
+	archive.Write(makeAESEntry("aes.txt", Deflate, 2, data, 0, uint32(len(plain))))
+	archive.Write(sigCentralDirectoryHeader)
+
+	passwords := [][]byte{[]byte("wrong"), []byte("secret")}
+	cz := New(bytes.NewReader(archive.Bytes()))
+	cz.RegisterPasswordHandler(func(string) ([]byte, error) {
+		pwd := passwords[0]
+		passwords = passwords[1:]
+		return pwd, nil
+	})
+	if !cz.Scan() {
+		t.Fatalf("Scan failed: %v", cz.Err())
+	}
+	if m := cz.Method(); m != Deflate {
+		t.Fatalf("Method: expect %d, but %d", Deflate, m)
+	}
+	if cz.HasCRC32() {
+		t.Fatal("HasCRC32: expect false for AE-2")
+	}
+	output, err := io.ReadAll(cz.Body())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !bytes.Equal(output, plain) {
+		t.Fatalf("Body: expect '%s', but '%s'", plain, output)
+	}
+}
+
+func TestWinZipAESAuthentication(t *testing.T) {
+	plain := []byte("The authentication code detects the broken data.")
+	data := encryptWinZipAES(t, []byte("secret"), []byte("01234567"), plain, 1)
+	data[len(data)-aesAuthCodeSize-1] ^= 0xFF
+
+	info := &_WinZipAES{Version: 1, Strength: 1}
+	holder := &_PasswordHolder{getter: func(string) ([]byte, error) { return []byte("secret"), nil }}
+	_, err := io.ReadAll(newAESDecrypter(bytes.NewReader(data), "test", info, holder))
+	if !errors.Is(err, ErrAuthentication) {
+		t.Fatalf("expect ErrAuthentication, but %v", err)
+	}
+}
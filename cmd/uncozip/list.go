@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hymkor/uncozip"
+)
+
+func methodName(method uint16) string {
+	switch method {
+	case uncozip.Store:
+		return "Stored"
+	case uncozip.Shrink:
+		return "Shrunk"
+	case uncozip.Reduce1, uncozip.Reduce2, uncozip.Reduce3, uncozip.Reduce4:
+		return fmt.Sprintf("Reduce%d", method-uncozip.Reduce1+1)
+	case uncozip.Implode:
+		return "Implode"
+	case uncozip.Deflate:
+		return "Defl:N"
+	case uncozip.Deflate64:
+		return "Def64"
+	case uncozip.Bzip2:
+		return "BZip2"
+	case uncozip.LZMA:
+		return "LZMA"
+	case uncozip.Zstd:
+		return "Zstd"
+	case uncozip.XZ:
+		return "XZ"
+	default:
+		return fmt.Sprintf("Unk:%03d", method)
+	}
+}
+
+// ratio returns the percentage saved by the compression as `unzip -v` does.
+func ratio(original, compressed uint64) int {
+	if original == 0 {
+		return 0
+	}
+	if compressed >= original {
+		return 0
+	}
+	return int((original - compressed) * 100 / original)
+}
+
+// listEntries prints the entries like `unzip -l` (or `unzip -v` when verbose is true)
+// without writing files. The entries with the data descriptor are read
+// to the end to know their sizes. When rep is not nil, the entries are
+// reported as JSON instead.
+func listEntries(cz *uncozip.CorruptedZip, patterns []string, verbose bool, rep *reporter) error {
+	w := os.Stdout
+	if rep != nil {
+		w = nil
+	}
+	if w != nil {
+		if verbose {
+			fmt.Fprintln(w, " Length   Method    Size  Cmpr    Date    Time   CRC-32   Enc  Name")
+			fmt.Fprintln(w, "--------  ------  ------- ---- ---------- ----- --------  ---  ----")
+		} else {
+			fmt.Fprintln(w, "  Length      Date    Time    Name")
+			fmt.Fprintln(w, "---------  ---------- -----   ----")
+		}
+	}
+	var count int
+	var totalOriginal, totalCompressed uint64
+	for entry := range cz.Each {
+		name := entry.Name()
+		if !matchingPatterns(name, patterns) {
+			continue
+		}
+		// The sizes in the data descriptor are known after the data is
+		// skipped without asking the password of the encrypted entries.
+		if entry.HasDataDescriptor() && !entry.IsDir() {
+			if err := entry.Skip(); err != nil {
+				rep.entry(entry, statusError, 0, false, err)
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		if rep != nil {
+			rep.entry(entry, statusListed, 0, false, nil)
+			continue
+		}
+		original := entry.OriginalSize()
+		compressed := entry.CompressedSize()
+		stamp := entry.LastModificationTime.Format("2006-01-02 15:04")
+		if verbose {
+			encrypted := ""
+			if entry.IsEncrypted() {
+				encrypted = "*"
+			}
+			fmt.Fprintf(w, "%8d  %-7s %7d %3d%% %s %08x  %-3s  %s\n",
+				original,
+				methodName(entry.Method()),
+				compressed,
+				ratio(original, compressed),
+				stamp,
+				entry.CRC32(),
+				encrypted,
+				name)
+		} else {
+			fmt.Fprintf(w, "%9d  %s   %s\n", original, stamp, name)
+		}
+		count++
+		totalOriginal += original
+		totalCompressed += compressed
+	}
+	if err := cz.Err(); err != nil && err != io.EOF {
+		return err
+	}
+	if w == nil {
+		return nil
+	}
+	files := "files"
+	if count == 1 {
+		files = "file"
+	}
+	if verbose {
+		fmt.Fprintln(w, "--------          -------  ---                                 -------")
+		fmt.Fprintf(w, "%8d          %7d %3d%%                                 %d %s\n",
+			totalOriginal, totalCompressed, ratio(totalOriginal, totalCompressed), count, files)
+	} else {
+		fmt.Fprintln(w, "---------                     -------")
+		fmt.Fprintf(w, "%9d                     %d %s\n", totalOriginal, count, files)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"hash/crc32"
+	"testing"
+
+	"github.com/hymkor/uncozip"
+)
+
+// readReports decodes the JSON objects written by the reporter.
+func readReports(t *testing.T, data []byte) []entryReport {
+	t.Helper()
+	var reports []entryReport
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for dec.More() {
+		var e entryReport
+		if err := dec.Decode(&e); err != nil {
+			t.Fatal(err.Error())
+		}
+		reports = append(reports, e)
+	}
+	return reports
+}
+
+func TestListUnknownMethod(t *testing.T) {
+	var archive bytes.Buffer
+	w := zip.NewWriter(&archive)
+	for _, method := range []uint16{97, zip.Store} {
+		data := []byte("data")
+		f, err := w.CreateRaw(&zip.FileHeader{
+			Name:               methodName(method),
+			Method:             method,
+			CRC32:              crc32.ChecksumIEEE(data),
+			CompressedSize64:   uint64(len(data)),
+			UncompressedSize64: uint64(len(data)),
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		f.Write(data)
+	}
+	w.Close()
+
+	var output bytes.Buffer
+	cz := uncozip.New(bytes.NewReader(archive.Bytes()))
+	if err := listEntries(cz, nil, true, newReporter(&output)); err != nil {
+		t.Fatal(err.Error())
+	}
+	reports := readReports(t, output.Bytes())
+	if len(reports) != 2 || reports[0].MethodName != "Unk:097" || reports[1].MethodName != "Stored" {
+		t.Fatalf("unexpected %+v", reports)
+	}
+}
@@ -22,11 +22,28 @@ import (
 )
 
 var (
-	flagDebug  = flag.Bool("debug", false, "Enable debug output")
-	flagTest   = flag.Bool("t", false, "Test CRC32")
-	flagExDir  = flag.String("d", "", "the directory where to extract")
-	flagStrict = flag.Bool("strict", false, "quit immediately on CRC-Error")
-	flagDecode = flag.String("decode", "", "IANA-registered-name to decode filename")
+	flagDebug         = flag.Bool("debug", false, "Enable debug output")
+	flagTest          = flag.Bool("t", false, "Test CRC32")
+	flagExDir         = flag.String("d", "", "the directory where to extract")
+	flagStrict        = flag.Bool("strict", false, "quit immediately on CRC-Error")
+	flagDecode        = flag.String("decode", "", "IANA-registered-name to decode filename")
+	flagRecover       = flag.Bool("recover", false, "skip broken data and continue from the next local file header")
+	flagStreamEnd     = flag.Bool("stream-end", false, "find the end of Deflate/LZMA entries with the data descriptor by the compressed stream instead of the signature")
+	flagList          = flag.Bool("l", false, "List entries (short format)")
+	flagVerbose       = flag.Bool("v", false, "List entries (verbose format)")
+	flagJSON          = flag.Bool("json", false, "Output the result of each entry as JSON to stdout")
+	flagRepair        = flag.String("repair", "", "Write the recoverable entries into the new ZIP file instead of extracting")
+	flagTar           = flag.Bool("tar", false, "Convert the entries into a tar stream to stdout instead of extracting")
+	flagPipe          = flag.Bool("p", false, "Extract files to stdout (pipe)")
+	flagNever         = flag.Bool("n", false, "Never overwrite existing files")
+	flagAlways        = flag.Bool("o", false, "Overwrite existing files without prompting")
+	flagNewer         = flag.Bool("newer", false, "Overwrite existing files only when the entries are newer")
+	flagRename        = flag.Bool("rename", false, "Extract into the new names with a suffix instead of overwriting existing files")
+	flagUmask         = flag.String("umask", "", "the octal mask to clear the permission bits restored from the archive (e.g. 022)")
+	flagPreserveOwner = flag.Bool("preserve-owner", false, "Restore the owner and the group of the files from the archive when running as root")
+	flagUIDMap        = flag.String("uid-map", "", "translate the user IDs for -preserve-owner (FROM:TO[,FROM:TO...])")
+	flagGIDMap        = flag.String("gid-map", "", "translate the group IDs for -preserve-owner (FROM:TO[,FROM:TO...])")
+	flagSymlink       = flag.String("symlink", symlinkSafe, "how to extract symbolic links: safe (only the links inside the directory), refuse, file (write the target path) or all")
 )
 
 func matchingPatterns(target string, patterns []string) bool {
@@ -56,6 +73,60 @@ func askPassword(name string) ([]byte, error) {
 	return []byte(passwordString), nil
 }
 
+// askOverwrite asks whether the existing file is overwritten as unzip does.
+func askOverwrite(name string) (uncozip.OverwritePolicy, bool, error) {
+	tty, err := tty.Open()
+	if err != nil {
+		return uncozip.OverwriteNever, false, fmt.Errorf("%s: already exists, but can not ask to overwrite: %w", name, err)
+	}
+	defer tty.Close()
+	for {
+		fmt.Fprintf(os.Stderr, "replace %s? [y]es, [n]o, [A]ll, [N]one, [r]ename: ", name)
+		answer, err := tty.ReadString()
+		if err != nil {
+			return uncozip.OverwriteNever, false, err
+		}
+		switch strings.TrimSpace(answer) {
+		case "y":
+			return uncozip.OverwriteAlways, false, nil
+		case "n":
+			return uncozip.OverwriteNever, false, nil
+		case "A":
+			return uncozip.OverwriteAlways, true, nil
+		case "N":
+			return uncozip.OverwriteNever, true, nil
+		case "r":
+			return uncozip.OverwriteRename, false, nil
+		}
+	}
+}
+
+// newOverwriter returns the Overwriter for the options. Without them,
+// it asks only when stdin is a terminal and otherwise overwrites as
+// the former versions did, so that scripts are not changed.
+func newOverwriter() *uncozip.Overwriter {
+	o := &uncozip.Overwriter{Policy: uncozip.OverwritePrompt, Prompt: askOverwrite}
+	switch {
+	case *flagAlways:
+		o.Policy = uncozip.OverwriteAlways
+	case *flagNever:
+		o.Policy = uncozip.OverwriteNever
+	case *flagNewer:
+		o.Policy = uncozip.OverwriteIfNewer
+	case *flagRename:
+		o.Policy = uncozip.OverwriteRename
+	case !term.IsTerminal(int(os.Stdin.Fd())):
+		o.Policy = uncozip.OverwriteAlways
+	}
+	return o
+}
+
+var overwriter *uncozip.Overwriter
+
+var modes *modeRestorer
+
+var owners *ownerRestorer
+
 var errSkipEntry = errors.New("SKIP ENTRY")
 
 func testEntry(cz *uncozip.CorruptedZip, patterns []string) (uint32, error) {
@@ -79,6 +150,18 @@ func testEntry(cz *uncozip.CorruptedZip, patterns []string) (uint32, error) {
 	return h.Sum32(), nil
 }
 
+func pipeEntry(cz *uncozip.CorruptedZip, patterns []string) (uint32, error) {
+	if cz.IsDir() || !matchingPatterns(cz.Name(), patterns) {
+		return 0, errSkipEntry
+	}
+	h := crc32.NewIEEE()
+	_, err := io.Copy(os.Stdout, io.TeeReader(cz.Body(), h))
+	if err != nil {
+		return 0, err
+	}
+	return h.Sum32(), nil
+}
+
 func extractEntry(cz *uncozip.CorruptedZip, patterns []string) (uint32, error) {
 	orgfname := cz.Name()
 	fname := uncozip.SanitizePath(orgfname)
@@ -88,16 +171,47 @@ func extractEntry(cz *uncozip.CorruptedZip, patterns []string) (uint32, error) {
 	}
 
 	if cz.IsDir() {
+		if err := checkParent(fname); err != nil {
+			fmt.Fprintf(os.Stderr, "   skipping: %s (%s)\n", fname, err.Error())
+			return 0, errSkipEntry
+		}
 		fmt.Fprintln(os.Stderr, "   creating:", fname)
-		if err := os.MkdirAll(fname, 0644); err != nil && !os.IsExist(err) {
+		if err := os.MkdirAll(fname, 0755); err != nil && !os.IsExist(err) {
 			return 0, err
 		}
+		modes.entry(cz, fname)
+		owners.entry(cz, fname)
 		return 0, nil
 	}
 	if !matchingPatterns(fname, patterns) {
 		return 0, errSkipEntry
 	}
-	_fname := filepath.FromSlash(fname)
+	if cz.IsSymlink() && *flagSymlink == symlinkRefuse {
+		fmt.Fprintf(os.Stderr, "   skipping: %s (symbolic link)\n", fname)
+		return 0, errSkipEntry
+	}
+	_fname, err := overwriter.Target(filepath.FromSlash(fname), cz.LastModificationTime)
+	if err != nil {
+		return 0, err
+	}
+	if _fname == "" {
+		fmt.Fprintln(os.Stderr, "   skipping:", fname)
+		return 0, errSkipEntry
+	}
+	if _fname != filepath.FromSlash(fname) {
+		fmt.Fprintf(os.Stderr, "   renaming: %s -> %s\n", fname, _fname)
+	}
+	if err := checkParent(_fname); err != nil {
+		fmt.Fprintf(os.Stderr, "   skipping: %s (%s)\n", fname, err.Error())
+		return 0, errSkipEntry
+	}
+	if cz.IsSymlink() && *flagSymlink != symlinkFile {
+		checksum, err := extractSymlink(cz, fname, _fname)
+		if err == nil {
+			owners.entry(cz, _fname)
+		}
+		return checksum, err
+	}
 	fd, err := os.Create(_fname)
 	if err != nil {
 		var pathError *os.PathError
@@ -112,7 +226,7 @@ func extractEntry(cz *uncozip.CorruptedZip, patterns []string) (uint32, error) {
 		if err2 == nil || !os.IsNotExist(err2) {
 			return 0, err
 		}
-		if err2 := os.MkdirAll(dir, 0750); err2 != nil {
+		if err2 := os.MkdirAll(dir, 0755); err2 != nil {
 			return 0, err2
 		}
 		fmt.Fprintf(os.Stderr, "   creating: %s/\n", dir)
@@ -122,10 +236,20 @@ func extractEntry(cz *uncozip.CorruptedZip, patterns []string) (uint32, error) {
 		}
 	}
 	switch cz.Method() {
-	case uncozip.Deflate:
+	case uncozip.Deflate, uncozip.Deflate64:
 		fmt.Fprintln(os.Stderr, "  inflating:", fname)
+	case uncozip.Shrink:
+		fmt.Fprintln(os.Stderr, "unshrinking:", fname)
+	case uncozip.Reduce1, uncozip.Reduce2, uncozip.Reduce3, uncozip.Reduce4:
+		fmt.Fprintln(os.Stderr, " unreducing:", fname)
+	case uncozip.Implode:
+		fmt.Fprintln(os.Stderr, "  exploding:", fname)
+	case uncozip.Bzip2:
+		fmt.Fprintln(os.Stderr, " bunzipping:", fname)
 	case uncozip.Store:
 		fmt.Fprintln(os.Stderr, " extracting:", fname)
+	default:
+		fmt.Fprintln(os.Stderr, "  inflating:", fname)
 	}
 	h := crc32.NewIEEE()
 	_, err = io.Copy(fd, io.TeeReader(cz.Body(), h))
@@ -136,23 +260,57 @@ func extractEntry(cz *uncozip.CorruptedZip, patterns []string) (uint32, error) {
 	if err1 != nil {
 		return 0, err1
 	}
-	if err := os.Chtimes(fname, cz.LastAccessTime, cz.LastModificationTime); err != nil {
+	if err := os.Chtimes(_fname, cz.LastAccessTime, cz.LastModificationTime); err != nil {
 		fmt.Fprintln(os.Stderr, fname, err.Error())
 	}
+	modes.entry(cz, _fname)
+	owners.entry(cz, _fname)
 	return h.Sum32(), nil
 }
 
 func mainForReader(r io.Reader, patterns []string) error {
+	var repairFd *os.File
+	if *flagRepair != "" {
+		var err error
+		repairFd, err = os.Create(*flagRepair)
+		if err != nil {
+			return err
+		}
+		defer repairFd.Close()
+	}
 	if *flagExDir != "" {
 		if err := os.Chdir(*flagExDir); err != nil {
 			return err
 		}
 	}
+	overwriter = newOverwriter()
+	if err := checkSymlinkFlag(); err != nil {
+		return err
+	}
+	var err error
+	if extractRoot, err = os.Getwd(); err != nil {
+		return err
+	}
+	if !*flagTest && !*flagPipe {
+		if modes, err = newModeRestorer(); err != nil {
+			return err
+		}
+		if owners, err = newOwnerRestorer(); err != nil {
+			return err
+		}
+	}
 	cz := uncozip.New(r)
 	cz.RegisterPasswordHandler(askPassword)
+	cz.DelimitByDecompressor = *flagStreamEnd
 	if *flagDebug {
 		cz.Debug = log.Println
 	}
+	if *flagRecover {
+		cz.RegisterRecoveryHandler(func(start, end int64) {
+			fmt.Fprintf(os.Stderr, "SKIP: %d bytes of broken data at offset %d-%d\n",
+				end-start, start, end)
+		})
+	}
 	if *flagDecode != "" {
 		e, err := ianaindex.IANA.Encoding(*flagDecode)
 		if err != nil {
@@ -171,36 +329,79 @@ func mainForReader(r io.Reader, patterns []string) error {
 		})
 	}
 
+	if repairFd != nil {
+		return repair(cz, repairFd)
+	}
+	if *flagTar {
+		return writeTar(cz, r, os.Stdout, patterns)
+	}
+	var rep *reporter
+	if *flagJSON {
+		if *flagPipe {
+			rep = newReporter(os.Stderr)
+		} else {
+			rep = newReporter(os.Stdout)
+		}
+	}
+	if *flagList || *flagVerbose {
+		err = listEntries(cz, patterns, *flagVerbose, rep)
+	} else {
+		err = processEntries(cz, patterns, rep)
+	}
+	rep.finish(err)
+	return err
+}
+
+func processEntries(cz *uncozip.CorruptedZip, patterns []string, rep *reporter) error {
 	for entry := range cz.Each {
 		var err error
 		var checksum uint32
 		if *flagTest {
 			checksum, err = testEntry(entry, patterns)
+		} else if *flagPipe {
+			checksum, err = pipeEntry(entry, patterns)
 		} else {
 			checksum, err = extractEntry(entry, patterns)
 		}
 		if err == errSkipEntry {
+			// The data is skipped to know the values in the data descriptor.
+			if err := entry.Skip(); err != nil {
+				rep.entry(entry, statusError, 0, false, err)
+				return err
+			}
+			rep.entry(entry, statusSkipped, 0, false, nil)
 			continue
 		}
 		if err != nil {
+			rep.entry(entry, statusError, 0, false, err)
 			return err
 		}
-		if checksum != entry.CRC32() {
+		computed := !entry.IsDir()
+		if !entry.HasCRC32() {
+			if *flagDebug {
+				fmt.Fprintf(os.Stderr, "%s: CRC32 is not stored (WinZip AE-2)\n", entry.Name())
+			}
+			rep.entry(entry, statusOK, checksum, computed, nil)
+		} else if checksum != entry.CRC32() {
+			err := fmt.Errorf("%s: CRC32 is expected %X in header, but %X",
+				entry.Name(), entry.CRC32(), checksum)
+			rep.entry(entry, statusCRCMismatch, checksum, computed, err)
 			if *flagStrict {
-				return fmt.Errorf("%s: CRC32 is expected %X in header, but %X",
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "NG:   %s\n", err.Error())
+		} else {
+			if *flagDebug {
+				fmt.Fprintf(os.Stderr, "%s: CRC32: header=%X , body=%X\n",
 					entry.Name(), entry.CRC32(), checksum)
 			}
-			fmt.Fprintf(os.Stderr,
-				"NG:   %s: CRC32 is expected %X in header, but %X\n",
-				entry.Name(), entry.CRC32(), checksum)
-		} else if *flagDebug {
-			fmt.Fprintf(os.Stderr, "%s: CRC32: header=%X , body=%X\n",
-				entry.Name(), entry.CRC32(), checksum)
+			rep.entry(entry, statusOK, checksum, computed, nil)
 		}
 	}
-	if err := cz.Err(); err != io.EOF {
+	if err := cz.Err(); err != nil && err != io.EOF {
 		return err
 	}
+	modes.finish(cz)
 	return nil
 }
 
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/hymkor/uncozip"
+)
+
+// modeRestorer restores the Unix permissions of the extracted files.
+// The mode in the local header is applied at once. The other files wait
+// for the central directory which follows the last entry. The modes of the
+// directories are applied at the end so that a read-only directory does not
+// prevent its files from being extracted.
+type modeRestorer struct {
+	umask   fs.FileMode
+	pending map[string]string // entry name -> extracted path
+	dirs    map[string]fs.FileMode
+}
+
+func newModeRestorer() (*modeRestorer, error) {
+	m := &modeRestorer{
+		pending: map[string]string{},
+		dirs:    map[string]fs.FileMode{},
+	}
+	if *flagUmask != "" {
+		umask, err := strconv.ParseUint(*flagUmask, 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("-umask %s: %w", *flagUmask, err)
+		}
+		m.umask = fs.FileMode(umask) & fs.ModePerm
+	}
+	return m, nil
+}
+
+// entry is called for each extracted entry with the path written.
+func (m *modeRestorer) entry(cz *uncozip.CorruptedZip, path string) {
+	if m == nil {
+		return
+	}
+	mode, ok := cz.Mode()
+	if !ok {
+		m.pending[cz.Name()] = path
+		return
+	}
+	if (mode & fs.ModeSymlink) != 0 {
+		// the link written as a file keeps the default mode
+		return
+	}
+	m.set(path, mode)
+}
+
+// set applies the mode to the file or keeps it for the directory.
+// The setuid, setgid and sticky bits are not restored.
+func (m *modeRestorer) set(path string, mode fs.FileMode) {
+	if mode.IsDir() {
+		m.dirs[path] = mode
+		return
+	}
+	if err := os.Chmod(path, mode.Perm()&^m.umask); err != nil {
+		fmt.Fprintln(os.Stderr, path, err.Error())
+	}
+}
+
+// finish reads the central directory for the pending files and applies
+// the modes of the directories.
+func (m *modeRestorer) finish(cz *uncozip.CorruptedZip) {
+	if m == nil {
+		return
+	}
+	if len(m.pending) > 0 {
+		entries, err := cz.ReadCentralDirectory()
+		if err != nil && *flagDebug {
+			fmt.Fprintln(os.Stderr, "central directory:", err.Error())
+		}
+		for _, e := range entries {
+			path, ok := m.pending[e.Name]
+			if !ok {
+				continue
+			}
+			mode, ok := e.Mode()
+			if !ok {
+				continue
+			}
+			if (mode & fs.ModeSymlink) != 0 {
+				convertSymlink(e.Name, path)
+			} else {
+				m.set(path, mode)
+			}
+		}
+	}
+	// The deeper directories first
+	paths := make([]string, 0, len(m.dirs))
+	for path := range m.dirs {
+		paths = append(paths, path)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(paths)))
+	for _, path := range paths {
+		if err := os.Chmod(path, m.dirs[path].Perm()&^m.umask); err != nil {
+			fmt.Fprintln(os.Stderr, path, err.Error())
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hymkor/uncozip"
+)
+
+// ownerRestorer changes the owner of the extracted files to the UID and GID
+// in the archive translated with the maps.
+type ownerRestorer struct {
+	uidMap map[int]int
+	gidMap map[int]int
+	owners map[string][2]int // path -> uid, gid applied
+}
+
+// parseIDMap parses "FROM:TO[,FROM:TO...]".
+func parseIDMap(s string) (map[int]int, error) {
+	m := map[int]int{}
+	if s == "" {
+		return m, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		from, to, ok := strings.Cut(pair, ":")
+		if !ok {
+			return nil, fmt.Errorf("%s: expect FROM:TO", pair)
+		}
+		f, err := strconv.Atoi(strings.TrimSpace(from))
+		if err != nil {
+			return nil, err
+		}
+		t, err := strconv.Atoi(strings.TrimSpace(to))
+		if err != nil {
+			return nil, err
+		}
+		m[f] = t
+	}
+	return m, nil
+}
+
+// newOwnerRestorer returns nil when -preserve-owner is not given or
+// uncozip is not running as root.
+func newOwnerRestorer() (*ownerRestorer, error) {
+	if !*flagPreserveOwner {
+		return nil, nil
+	}
+	uidMap, err := parseIDMap(*flagUIDMap)
+	if err != nil {
+		return nil, fmt.Errorf("-uid-map: %w", err)
+	}
+	gidMap, err := parseIDMap(*flagGIDMap)
+	if err != nil {
+		return nil, fmt.Errorf("-gid-map: %w", err)
+	}
+	if os.Geteuid() != 0 {
+		fmt.Fprintln(os.Stderr, "-preserve-owner is ignored because uncozip is not running as root")
+		return nil, nil
+	}
+	return &ownerRestorer{
+		uidMap: uidMap,
+		gidMap: gidMap,
+		owners: map[string][2]int{},
+	}, nil
+}
+
+// entry changes the owner of the file `path` extracted from the entry.
+func (o *ownerRestorer) entry(cz *uncozip.CorruptedZip, path string) {
+	if o == nil {
+		return
+	}
+	uid, gid, ok := cz.Owner()
+	if !ok {
+		return
+	}
+	if u, ok := o.uidMap[uid]; ok {
+		uid = u
+	}
+	if g, ok := o.gidMap[gid]; ok {
+		gid = g
+	}
+	o.set(path, uid, gid)
+}
+
+func (o *ownerRestorer) set(path string, uid, gid int) {
+	if err := os.Lchown(path, uid, gid); err != nil {
+		fmt.Fprintln(os.Stderr, path, err.Error())
+		return
+	}
+	o.owners[path] = [2]int{uid, gid}
+}
+
+// replaced gives the owner again to the file `path` created instead of the
+// file extracted before.
+func (o *ownerRestorer) replaced(path string) {
+	if o == nil {
+		return
+	}
+	if id, ok := o.owners[path]; ok {
+		o.set(path, id[0], id[1])
+	}
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hymkor/uncozip"
+)
+
+func repair(cz *uncozip.CorruptedZip, fd *os.File) error {
+	n, err := uncozip.Repair(cz, fd)
+	err1 := fd.Close()
+	fmt.Fprintf(os.Stderr, "%d entries were written into %s\n", n, fd.Name())
+	if err != nil {
+		return err
+	}
+	return err1
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/hymkor/uncozip"
+)
+
+const (
+	statusOK          = "ok"
+	statusCRCMismatch = "crc_mismatch"
+	statusError       = "error"
+	statusSkipped     = "skipped"
+	statusListed      = "listed"
+)
+
+type entryReport struct {
+	Type           string    `json:"type"`
+	Name           string    `json:"name"`
+	RawName        string    `json:"raw_name"`
+	Method         uint16    `json:"method"`
+	MethodName     string    `json:"method_name"`
+	CompressedSize *uint64   `json:"compressed_size,omitempty"`
+	OriginalSize   *uint64   `json:"original_size,omitempty"`
+	CRC32Header    string    `json:"crc32_header,omitempty"`
+	CRC32Computed  string    `json:"crc32_computed,omitempty"`
+	Modified       time.Time `json:"modified"`
+	Accessed       time.Time `json:"accessed"`
+	Created        time.Time `json:"created"`
+	Encrypted      bool      `json:"encrypted"`
+	Directory      bool      `json:"directory"`
+	Offset         int64     `json:"offset"`
+	Status         string    `json:"status"`
+	Error          string    `json:"error,omitempty"`
+}
+
+type summaryReport struct {
+	Type           string `json:"type"`
+	Entries        int    `json:"entries"`
+	OK             int    `json:"ok"`
+	CRCMismatch    int    `json:"crc_mismatch"`
+	Errors         int    `json:"errors"`
+	Skipped        int    `json:"skipped"`
+	Listed         int    `json:"listed"`
+	OriginalSize   uint64 `json:"original_size"`
+	CompressedSize uint64 `json:"compressed_size"`
+	Error          string `json:"error,omitempty"`
+}
+
+// reporter writes one JSON object per entry and the summary at the end.
+// The methods of a nil reporter do nothing.
+type reporter struct {
+	enc     *json.Encoder
+	summary summaryReport
+}
+
+func newReporter(w io.Writer) *reporter {
+	return &reporter{
+		enc:     json.NewEncoder(w),
+		summary: summaryReport{Type: "summary"},
+	}
+}
+
+// entry reports the current entry. checksum is the CRC32 computed from
+// the body and used only when computed is true.
+func (r *reporter) entry(cz *uncozip.CorruptedZip, status string, checksum uint32, computed bool, err error) {
+	if r == nil {
+		return
+	}
+	e := &entryReport{
+		Type:       "entry",
+		Name:       cz.Name(),
+		RawName:    hex.EncodeToString(cz.RawName()),
+		Method:     cz.Method(),
+		MethodName: methodName(cz.Method()),
+		Modified:   cz.LastModificationTime,
+		Accessed:   cz.LastAccessTime,
+		Created:    cz.CreationTime,
+		Encrypted:  cz.IsEncrypted(),
+		Directory:  cz.IsDir(),
+		Offset:     cz.LocalHeaderOffset(),
+		Status:     status,
+	}
+	// The values in the data descriptor are unknown when the body is not read
+	// all because of the error.
+	if status != statusError || !cz.HasDataDescriptor() {
+		compressed := cz.CompressedSize()
+		original := cz.OriginalSize()
+		e.CompressedSize = &compressed
+		e.OriginalSize = &original
+		if cz.HasCRC32() {
+			e.CRC32Header = fmt.Sprintf("%08X", cz.CRC32())
+		}
+		r.summary.CompressedSize += compressed
+		r.summary.OriginalSize += original
+	}
+	if computed {
+		e.CRC32Computed = fmt.Sprintf("%08X", checksum)
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	r.summary.Entries++
+	switch status {
+	case statusOK:
+		r.summary.OK++
+	case statusCRCMismatch:
+		r.summary.CRCMismatch++
+	case statusError:
+		r.summary.Errors++
+	case statusSkipped:
+		r.summary.Skipped++
+	case statusListed:
+		r.summary.Listed++
+	}
+	r.enc.Encode(e)
+}
+
+// finish reports the summary with the error which stopped reading the archive.
+func (r *reporter) finish(err error) {
+	if r == nil {
+		return
+	}
+	if err != nil {
+		r.summary.Error = err.Error()
+	}
+	r.enc.Encode(&r.summary)
+}
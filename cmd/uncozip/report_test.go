@@ -0,0 +1,110 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"testing"
+
+	"github.com/hymkor/uncozip"
+)
+
+type rawEntry struct {
+	name string
+	data []byte
+	crc  uint32
+	dd   bool
+}
+
+// makeArchive stores the entries with the CRC32 as given.
+func makeArchive(t *testing.T, entries ...rawEntry) []byte {
+	t.Helper()
+	var archive bytes.Buffer
+	w := zip.NewWriter(&archive)
+	for _, e := range entries {
+		var flags uint16
+		if e.dd {
+			flags = 0x8 // the data descriptor is used
+		}
+		f, err := w.CreateRaw(&zip.FileHeader{
+			Name:               e.name,
+			Method:             zip.Store,
+			Flags:              flags,
+			CRC32:              e.crc,
+			CompressedSize64:   uint64(len(e.data)),
+			UncompressedSize64: uint64(len(e.data)),
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		f.Write(e.data)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	return archive.Bytes()
+}
+
+func TestReportCRCMismatchWithDataDescriptor(t *testing.T) {
+	data := []byte("broken")
+	sum := crc32.ChecksumIEEE(data)
+	archive := makeArchive(t, rawEntry{"broken.txt", data, sum + 1, true})
+
+	save := *flagTest
+	*flagTest = true
+	defer func() { *flagTest = save }()
+
+	var output bytes.Buffer
+	rep := newReporter(&output)
+	cz := uncozip.New(bytes.NewReader(archive))
+	if err := processEntries(cz, nil, rep); err != nil {
+		t.Fatal(err.Error())
+	}
+	reports := readReports(t, output.Bytes())
+	if len(reports) != 1 {
+		t.Fatalf("expect one entry, but %+v", reports)
+	}
+	e := reports[0]
+	if e.Status != statusCRCMismatch ||
+		e.CRC32Header != fmt.Sprintf("%08X", sum+1) ||
+		e.CRC32Computed != fmt.Sprintf("%08X", sum) ||
+		e.CompressedSize == nil || *e.CompressedSize != uint64(len(data)) ||
+		e.OriginalSize == nil || *e.OriginalSize != uint64(len(data)) {
+		t.Fatalf("unexpected %+v", e)
+	}
+	if rep.summary.CRCMismatch != 1 || rep.summary.OriginalSize != uint64(len(data)) {
+		t.Fatalf("unexpected summary %+v", rep.summary)
+	}
+}
+
+func TestReportSkipped(t *testing.T) {
+	data := []byte("skipped")
+	sum := crc32.ChecksumIEEE(data)
+	archive := makeArchive(t,
+		rawEntry{"a.txt", data, sum, false},
+		rawEntry{"b.dat", data, sum, true},
+	)
+
+	save := *flagTest
+	*flagTest = true
+	defer func() { *flagTest = save }()
+
+	var output bytes.Buffer
+	rep := newReporter(&output)
+	cz := uncozip.New(bytes.NewReader(archive))
+	if err := processEntries(cz, []string{"*.txt"}, rep); err != nil {
+		t.Fatal(err.Error())
+	}
+	reports := readReports(t, output.Bytes())
+	if len(reports) != 2 || reports[0].Status != statusOK || reports[1].Status != statusSkipped {
+		t.Fatalf("unexpected %+v", reports)
+	}
+	if e := reports[1]; e.Name != "b.dat" || e.CRC32Header != fmt.Sprintf("%08X", sum) ||
+		e.OriginalSize == nil || *e.OriginalSize != uint64(len(data)) {
+		t.Fatalf("unexpected %+v", e)
+	}
+	if rep.summary.Entries != 2 || rep.summary.Skipped != 1 {
+		t.Fatalf("unexpected summary %+v", rep.summary)
+	}
+}
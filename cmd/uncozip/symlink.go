@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/hymkor/uncozip"
+)
+
+// The values of the option -symlink
+const (
+	symlinkSafe   = "safe"   // create links only when the target is inside the directory
+	symlinkRefuse = "refuse" // skip links
+	symlinkFile   = "file"   // write the target path into the regular file
+	symlinkAll    = "all"    // create all links
+)
+
+// maxSymlinkTarget is the maximum length of the target path of a link.
+const maxSymlinkTarget = 4096
+
+var errSymlinkTooLong = errors.New("the target of the symbolic link is too long")
+
+// extractRoot is the absolute path of the directory to extract into.
+var extractRoot string
+
+func checkSymlinkFlag() error {
+	switch *flagSymlink {
+	case symlinkSafe, symlinkRefuse, symlinkFile, symlinkAll:
+		return nil
+	}
+	return fmt.Errorf("-symlink %s: expect %s, %s, %s or %s",
+		*flagSymlink, symlinkSafe, symlinkRefuse, symlinkFile, symlinkAll)
+}
+
+// checkParent returns an error when a parent directory of path is a
+// symbolic link pointing outside of the extraction directory.
+func checkParent(path string) error {
+	if *flagSymlink == symlinkAll {
+		return nil
+	}
+	return uncozip.CheckSymlink(extractRoot, path, ".")
+}
+
+// extractSymlink creates the link `path` from the entry known as a symbolic
+// link by the local header.
+func extractSymlink(cz *uncozip.CorruptedZip, fname, path string) (uint32, error) {
+	h := crc32.NewIEEE()
+	target, err := io.ReadAll(io.LimitReader(io.TeeReader(cz.Body(), h), maxSymlinkTarget+1))
+	if err != nil {
+		return 0, err
+	}
+	if len(target) > maxSymlinkTarget {
+		return 0, fmt.Errorf("%s: %w", fname, errSymlinkTooLong)
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return 0, err
+		}
+	}
+	if !makeSymlink(fname, path, string(target)) {
+		return h.Sum32(), errSkipEntry
+	}
+	return h.Sum32(), nil
+}
+
+// makeSymlink creates the link `path` to `target` along the option -symlink
+// and returns true when the link or the file instead is created.
+func makeSymlink(fname, path, target string) bool {
+	if *flagSymlink == symlinkSafe {
+		if err := uncozip.CheckSymlink(extractRoot, path, target); err != nil {
+			fmt.Fprintf(os.Stderr, "   skipping: %s -> %s (%s)\n", fname, target, err.Error())
+			return false
+		}
+	}
+	os.Remove(path)
+	if err := os.Symlink(target, path); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s. the target is written into the file\n", fname, err.Error())
+		if err := os.WriteFile(path, []byte(target), 0644); err != nil {
+			fmt.Fprintln(os.Stderr, fname, err.Error())
+			return false
+		}
+		return true
+	}
+	fmt.Fprintf(os.Stderr, "    linking: %s -> %s\n", fname, target)
+	return true
+}
+
+// convertSymlink replaces the file which the entry known as a symbolic link
+// by the central directory was extracted into.
+func convertSymlink(fname, path string) {
+	switch *flagSymlink {
+	case symlinkFile:
+		return
+	case symlinkRefuse:
+		fmt.Fprintf(os.Stderr, "   removing: %s (symbolic link)\n", fname)
+		os.Remove(path)
+		return
+	}
+	target, err := os.ReadFile(path)
+	if err == nil && len(target) > maxSymlinkTarget {
+		err = errSymlinkTooLong
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fname, err.Error())
+		return
+	}
+	os.Remove(path)
+	if makeSymlink(fname, path, string(target)) {
+		owners.replaced(path)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/hymkor/uncozip"
+)
+
+// The modes written to the tar header when the archive does not have them
+const (
+	tarDirMode  = 0755
+	tarFileMode = 0644
+)
+
+var errNoPassword = errors.New("the password is not asked to read the central directory")
+
+// readModesInAdvance returns the modes in the central directory by their raw
+// names when r is a regular file. The tar header has to be written before the
+// data, but Info-ZIP writes the Unix modes only in the central directory
+// following the last entry. It returns nil when they can not be read.
+func readModesInAdvance(r io.Reader) map[string]fs.FileMode {
+	fd, ok := r.(*os.File)
+	if !ok {
+		return nil
+	}
+	stat, err := fd.Stat()
+	if err != nil || !stat.Mode().IsRegular() {
+		return nil
+	}
+	offset, err := fd.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil
+	}
+	cz := uncozip.New(io.NewSectionReader(fd, offset, stat.Size()-offset))
+	cz.RegisterPasswordHandler(func(string) ([]byte, error) { return nil, errNoPassword })
+	for entry := range cz.Each {
+		if err := entry.Skip(); err != nil {
+			return nil
+		}
+	}
+	entries, err := cz.ReadCentralDirectory()
+	if err != nil && *flagDebug {
+		fmt.Fprintln(os.Stderr, "central directory:", err.Error())
+	}
+	modes := map[string]fs.FileMode{}
+	for _, e := range entries {
+		if mode, ok := e.Mode(); ok {
+			modes[string(e.RawName)] = mode
+		}
+	}
+	return modes
+}
+
+// warnDefaultModes reads the central directory after the last entry and
+// reports the entries written with the default modes though it has their modes.
+func warnDefaultModes(cz *uncozip.CorruptedZip, defaults map[string]string) {
+	if len(defaults) <= 0 {
+		return
+	}
+	entries, err := cz.ReadCentralDirectory()
+	if err != nil && *flagDebug {
+		fmt.Fprintln(os.Stderr, "central directory:", err.Error())
+	}
+	count := 0
+	for _, e := range entries {
+		name, ok := defaults[string(e.RawName)]
+		if !ok {
+			continue
+		}
+		mode, ok := e.Mode()
+		if !ok {
+			continue
+		}
+		if (mode & fs.ModeSymlink) != 0 {
+			fmt.Fprintf(os.Stderr, "%s: the symbolic link is written as a regular file because its mode is only in the central directory\n", name)
+		} else if (mode.IsDir() && mode.Perm() != tarDirMode) || (!mode.IsDir() && mode.Perm() != tarFileMode) {
+			count++
+		}
+	}
+	if count > 0 {
+		fmt.Fprintf(os.Stderr, "%d entries are written with the default modes because their modes are only in the central directory. Give the archive as a file to read them.\n", count)
+	}
+}
+
+// writeTar converts the entries into a tar stream written to w.
+// The modes only in the central directory are read in advance when r is
+// a regular file. Otherwise those entries are written with the default modes
+// and the ones which have to be the symbolic links are reported at the end.
+func writeTar(cz *uncozip.CorruptedZip, r io.Reader, w io.Writer, patterns []string) error {
+	cdModes := readModesInAdvance(r)
+	defaults := map[string]string{} // raw name -> name written with the default mode
+	tw := tar.NewWriter(w)
+	for entry := range cz.Each {
+		name := filepath.ToSlash(uncozip.SanitizePath(entry.Name()))
+		if !matchingPatterns(name, patterns) {
+			continue
+		}
+		hdr := &tar.Header{
+			Name:       name,
+			ModTime:    entry.LastModificationTime,
+			AccessTime: entry.LastAccessTime,
+			Format:     tar.FormatPAX,
+		}
+		if uid, gid, ok := entry.Owner(); ok {
+			hdr.Uid = uid
+			hdr.Gid = gid
+		}
+		mode, hasMode := entry.Mode()
+		if !hasMode {
+			mode, hasMode = cdModes[string(entry.RawName())]
+		}
+		if !hasMode {
+			defaults[string(entry.RawName())] = entry.Name()
+		}
+		if entry.IsDir() {
+			hdr.Typeflag = tar.TypeDir
+			hdr.Name += "/"
+			hdr.Mode = tarDirMode
+			if hasMode {
+				hdr.Mode = int64(mode.Perm())
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			continue
+		}
+		if hasMode && (mode&fs.ModeSymlink) != 0 {
+			target, err := io.ReadAll(io.LimitReader(entry.Body(), maxSymlinkTarget+1))
+			if err == nil && len(target) > maxSymlinkTarget {
+				err = errSymlinkTooLong
+			}
+			if err == nil {
+				hdr.Typeflag = tar.TypeSymlink
+				hdr.Linkname = string(target)
+				hdr.Mode = 0777
+				err = tw.WriteHeader(hdr)
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", entry.Name(), err)
+			}
+			continue
+		}
+		hdr.Typeflag = tar.TypeReg
+		hdr.Mode = tarFileMode
+		if hasMode {
+			hdr.Mode = int64(mode.Perm())
+		}
+
+		h := crc32.NewIEEE()
+		if err := writeTarBody(tw, hdr, entry, io.TeeReader(entry.Body(), h)); err != nil {
+			return fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		if entry.HasCRC32() && h.Sum32() != entry.CRC32() {
+			if *flagStrict {
+				return fmt.Errorf("%s: CRC32 is expected %X in header, but %X",
+					entry.Name(), entry.CRC32(), h.Sum32())
+			}
+			fmt.Fprintf(os.Stderr, "NG:   %s: CRC32 is expected %X in header, but %X\n",
+				entry.Name(), entry.CRC32(), h.Sum32())
+		}
+	}
+	if err := cz.Err(); err != nil && err != io.EOF {
+		return err
+	}
+	warnDefaultModes(cz, defaults)
+	return tw.Close()
+}
+
+// writeTarBody writes the header and the data of the regular file.
+func writeTarBody(tw *tar.Writer, hdr *tar.Header, cz *uncozip.CorruptedZip, body io.Reader) error {
+	if !cz.HasDataDescriptor() {
+		hdr.Size = int64(cz.OriginalSize())
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, body); err != nil {
+			return err
+		}
+		return tw.Flush()
+	}
+	// The size has to be written in the tar header before the data.
+	s, err := uncozip.NewSpool(body, uncozip.DefaultSpoolMemLimit, "")
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	hdr.Size = s.Size()
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := s.WriteTo(tw); err != nil {
+		return err
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hymkor/uncozip"
+)
+
+func TestTarModeInCentralDirectory(t *testing.T) {
+	// The Info-ZIP New Unix extra field: version 1, UID 1000 and GID 100
+	var owner bytes.Buffer
+	binary.Write(&owner, binary.LittleEndian, [2]uint16{0x7875, 11})
+	owner.Write([]byte{1, 4})
+	binary.Write(&owner, binary.LittleEndian, uint32(1000))
+	owner.WriteByte(4)
+	binary.Write(&owner, binary.LittleEndian, uint32(100))
+
+	// archive/zip writes the modes only in the central directory like Info-ZIP.
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for _, f := range []struct {
+		name string
+		mode fs.FileMode
+		data string
+	}{
+		{"bin/", fs.ModeDir | 0700, ""},
+		{"bin/run.sh", 0755, "#!/bin/sh\n"},
+		{"bin/link", fs.ModeSymlink | 0777, "run.sh"},
+	} {
+		h := &zip.FileHeader{Name: f.name, Method: zip.Deflate, Extra: owner.Bytes()}
+		h.SetMode(f.mode)
+		w, err := zw.CreateHeader(h)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		io.WriteString(w, f.data)
+	}
+	zw.Close()
+
+	path := filepath.Join(t.TempDir(), "modes.zip")
+	if err := os.WriteFile(path, archive.Bytes(), 0644); err != nil {
+		t.Fatal(err.Error())
+	}
+	fd, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer fd.Close()
+
+	var output bytes.Buffer
+	if err := writeTar(uncozip.New(fd), fd, &output, nil); err != nil {
+		t.Fatal(err.Error())
+	}
+	expect := map[string]struct {
+		typeflag byte
+		mode     int64
+		linkname string
+	}{
+		"bin/":       {tar.TypeDir, 0700, ""},
+		"bin/run.sh": {tar.TypeReg, 0755, ""},
+		"bin/link":   {tar.TypeSymlink, 0777, "run.sh"},
+	}
+	tr := tar.NewReader(&output)
+	count := 0
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		count++
+		e, ok := expect[hdr.Name]
+		if !ok {
+			t.Fatalf("unexpected entry %s", hdr.Name)
+		}
+		if hdr.Typeflag != e.typeflag || hdr.Mode != e.mode || hdr.Linkname != e.linkname {
+			t.Fatalf("%s: expect %c %o '%s', but %c %o '%s'", hdr.Name,
+				e.typeflag, e.mode, e.linkname, hdr.Typeflag, hdr.Mode, hdr.Linkname)
+		}
+		if hdr.Uid != 1000 || hdr.Gid != 100 {
+			t.Fatalf("%s: expect 1000/100, but %d/%d", hdr.Name, hdr.Uid, hdr.Gid)
+		}
+	}
+	if count != len(expect) {
+		t.Fatalf("expect %d entries, but %d", len(expect), count)
+	}
+}
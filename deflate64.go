@@ -0,0 +1,429 @@
+package uncozip
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+// Deflate64 (Enhanced Deflating) is the same as Deflate except:
+//   - the window size is 64KB instead of 32KB
+//   - the length code 285 has 16 extra bits and its base length is 3
+//   - the distance codes 30 and 31 are used for distances up to 65536
+
+var ErrDeflate64Data = errors.New("deflate64: data error")
+
+var errInvalidCode = errors.New("invalid Huffman code")
+
+const (
+	deflate64WindowSize = 1 << 16
+	deflate64WindowMask = deflate64WindowSize - 1
+	deflate64MaxBits    = 15
+)
+
+var deflate64LengthBase = [...]uint16{
+	3, 4, 5, 6, 7, 8, 9, 10, 11, 13, 15, 17, 19, 23, 27, 31,
+	35, 43, 51, 59, 67, 83, 99, 115, 131, 163, 195, 227, 3}
+
+var deflate64LengthExtra = [...]uint8{
+	0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 2, 2, 2, 2,
+	3, 3, 3, 3, 4, 4, 4, 4, 5, 5, 5, 5, 16}
+
+var deflate64DistBase = [...]uint32{
+	1, 2, 3, 4, 5, 7, 9, 13, 17, 25, 33, 49, 65, 97, 129, 193,
+	257, 385, 513, 769, 1025, 1537, 2049, 3073, 4097, 6145,
+	8193, 12289, 16385, 24577, 32769, 49153}
+
+var deflate64DistExtra = [...]uint8{
+	0, 0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 6,
+	7, 7, 8, 8, 9, 9, 10, 10, 11, 11,
+	12, 12, 13, 13, 14, 14}
+
+// codeLengthOrder is the order of the code length code lengths in the dynamic block header.
+var codeLengthOrder = [...]int{16, 17, 18, 0, 8, 7, 9, 6, 10, 5, 11, 4, 12, 3, 13, 2, 14, 1, 15}
+
+// _HuffmanTable is a lookup table indexed by the next maxBits bits
+// (LSB-first) whose values are symbol<<5 | code length.
+type _HuffmanTable struct {
+	table   []uint32
+	maxBits uint
+}
+
+func reverseBits(code uint32, n uint) uint32 {
+	var result uint32
+	for i := uint(0); i < n; i++ {
+		result = (result << 1) | (code & 1)
+		code >>= 1
+	}
+	return result
+}
+
+func newHuffmanTable(lengths []uint8) (*_HuffmanTable, error) {
+	var count [deflate64MaxBits + 1]int
+	maxBits := uint(0)
+	for _, n := range lengths {
+		count[n]++
+		if uint(n) > maxBits {
+			maxBits = uint(n)
+		}
+	}
+	if maxBits == 0 {
+		return &_HuffmanTable{table: []uint32{0}}, nil
+	}
+	left := 1
+	for i := 1; i <= deflate64MaxBits; i++ {
+		left <<= 1
+		left -= count[i]
+		if left < 0 {
+			return nil, ErrDeflate64Data
+		}
+	}
+	var next [deflate64MaxBits + 2]uint32
+	code := uint32(0)
+	count[0] = 0
+	for i := 1; i <= deflate64MaxBits; i++ {
+		code = (code + uint32(count[i-1])) << 1
+		next[i] = code
+	}
+	h := &_HuffmanTable{
+		table:   make([]uint32, 1<<maxBits),
+		maxBits: maxBits,
+	}
+	for symbol, n := range lengths {
+		if n == 0 {
+			continue
+		}
+		r := reverseBits(next[n], uint(n))
+		next[n]++
+		for j := r; j < uint32(len(h.table)); j += 1 << n {
+			h.table[j] = uint32(symbol)<<5 | uint32(n)
+		}
+	}
+	return h, nil
+}
+
+type _BitReader struct {
+	br    io.ByteReader
+	bits  uint64
+	nbits uint
+}
+
+func (b *_BitReader) fill(n uint) error {
+	for b.nbits < n {
+		c, err := b.br.ReadByte()
+		if err != nil {
+			return err
+		}
+		b.bits |= uint64(c) << b.nbits
+		b.nbits += 8
+	}
+	return nil
+}
+
+func (b *_BitReader) getBits(n uint) (uint32, error) {
+	if err := b.fill(n); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	v := uint32(b.bits & ((1 << n) - 1))
+	b.bits >>= n
+	b.nbits -= n
+	return v, nil
+}
+
+func (b *_BitReader) alignToByte() {
+	n := b.nbits % 8
+	b.bits >>= n
+	b.nbits -= n
+}
+
+// decode reads bytes only as many as the code requires,
+// so that it does not read beyond the end of the compressed data.
+func (b *_BitReader) decode(h *_HuffmanTable) (uint32, error) {
+	for {
+		entry := h.table[b.bits&((1<<h.maxBits)-1)]
+		if n := uint(entry & 31); n > 0 && n <= b.nbits {
+			b.bits >>= n
+			b.nbits -= n
+			return entry >> 5, nil
+		}
+		if b.nbits >= h.maxBits {
+			return 0, errInvalidCode
+		}
+		if err := b.fill(b.nbits + 1); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+	}
+}
+
+var fixedLitLen, fixedDist *_HuffmanTable
+
+func init() {
+	var lengths [288]uint8
+	for i := range lengths {
+		switch {
+		case i < 144:
+			lengths[i] = 8
+		case i < 256:
+			lengths[i] = 9
+		case i < 280:
+			lengths[i] = 7
+		default:
+			lengths[i] = 8
+		}
+	}
+	fixedLitLen, _ = newHuffmanTable(lengths[:])
+	var dist [32]uint8
+	for i := range dist {
+		dist[i] = 5
+	}
+	fixedDist, _ = newHuffmanTable(dist[:])
+}
+
+type deflate64Reader struct {
+	_BitReader
+	window   [deflate64WindowSize]byte
+	wpos     int
+	total    int64
+	final    bool
+	inBlock  bool
+	stored   int
+	litLen   *_HuffmanTable
+	dist     *_HuffmanTable
+	copyLen  int
+	copyDist int
+	err      error
+}
+
+func newDeflate64Reader(r io.Reader) io.ReadCloser {
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &deflate64Reader{_BitReader: _BitReader{br: br}}
+}
+
+func (d *deflate64Reader) readDynamicTables() error {
+	hlit, err := d.getBits(5)
+	if err != nil {
+		return err
+	}
+	hdist, err := d.getBits(5)
+	if err != nil {
+		return err
+	}
+	hclen, err := d.getBits(4)
+	if err != nil {
+		return err
+	}
+	var clen [19]uint8
+	for i := 0; i < int(hclen)+4; i++ {
+		v, err := d.getBits(3)
+		if err != nil {
+			return err
+		}
+		clen[codeLengthOrder[i]] = uint8(v)
+	}
+	ch, err := newHuffmanTable(clen[:])
+	if err != nil {
+		return err
+	}
+	nlit := int(hlit) + 257
+	ndist := int(hdist) + 1
+	lengths := make([]uint8, nlit+ndist)
+	for i := 0; i < len(lengths); {
+		symbol, err := d.decode(ch)
+		if err != nil {
+			return err
+		}
+		if symbol < 16 {
+			lengths[i] = uint8(symbol)
+			i++
+			continue
+		}
+		var value uint8
+		var repeat uint32
+		switch symbol {
+		case 16:
+			if i == 0 {
+				return ErrDeflate64Data
+			}
+			value = lengths[i-1]
+			repeat, err = d.getBits(2)
+			repeat += 3
+		case 17:
+			repeat, err = d.getBits(3)
+			repeat += 3
+		default:
+			repeat, err = d.getBits(7)
+			repeat += 11
+		}
+		if err != nil {
+			return err
+		}
+		if i+int(repeat) > len(lengths) {
+			return ErrDeflate64Data
+		}
+		for ; repeat > 0; repeat-- {
+			lengths[i] = value
+			i++
+		}
+	}
+	if lengths[256] == 0 {
+		return ErrDeflate64Data
+	}
+	if d.litLen, err = newHuffmanTable(lengths[:nlit]); err != nil {
+		return err
+	}
+	d.dist, err = newHuffmanTable(lengths[nlit:])
+	return err
+}
+
+func (d *deflate64Reader) readBlockHeader() error {
+	header, err := d.getBits(3)
+	if err != nil {
+		return err
+	}
+	d.final = (header & 1) != 0
+	switch header >> 1 {
+	case 0:
+		d.alignToByte()
+		length, err := d.getBits(16)
+		if err != nil {
+			return err
+		}
+		nlength, err := d.getBits(16)
+		if err != nil {
+			return err
+		}
+		if length != ^nlength&0xFFFF {
+			return ErrDeflate64Data
+		}
+		d.stored = int(length)
+		d.litLen = nil
+	case 1:
+		d.litLen = fixedLitLen
+		d.dist = fixedDist
+	case 2:
+		if err := d.readDynamicTables(); err != nil {
+			return err
+		}
+	default:
+		return ErrDeflate64Data
+	}
+	d.inBlock = true
+	return nil
+}
+
+func (d *deflate64Reader) put(b byte) {
+	d.window[d.wpos] = b
+	d.wpos = (d.wpos + 1) & deflate64WindowMask
+	d.total++
+}
+
+// step decodes the next symbol of the current block.
+// It returns a literal byte and true, or sets copyLen for a match.
+func (d *deflate64Reader) step() (byte, bool, error) {
+	if d.litLen == nil {
+		if d.stored <= 0 {
+			d.inBlock = false
+			return 0, false, nil
+		}
+		v, err := d.getBits(8)
+		if err != nil {
+			return 0, false, err
+		}
+		d.stored--
+		return byte(v), true, nil
+	}
+	symbol, err := d.decode(d.litLen)
+	if err != nil {
+		return 0, false, err
+	}
+	if symbol < 256 {
+		return byte(symbol), true, nil
+	}
+	if symbol == 256 {
+		d.inBlock = false
+		return 0, false, nil
+	}
+	symbol -= 257
+	if int(symbol) >= len(deflate64LengthBase) {
+		return 0, false, ErrDeflate64Data
+	}
+	extra, err := d.getBits(uint(deflate64LengthExtra[symbol]))
+	if err != nil {
+		return 0, false, err
+	}
+	length := int(deflate64LengthBase[symbol]) + int(extra)
+
+	symbol, err = d.decode(d.dist)
+	if err != nil {
+		return 0, false, err
+	}
+	if int(symbol) >= len(deflate64DistBase) {
+		return 0, false, ErrDeflate64Data
+	}
+	extra, err = d.getBits(uint(deflate64DistExtra[symbol]))
+	if err != nil {
+		return 0, false, err
+	}
+	dist := int(deflate64DistBase[symbol]) + int(extra)
+	if int64(dist) > d.total || dist > deflate64WindowSize {
+		return 0, false, ErrDeflate64Data
+	}
+	d.copyLen = length
+	d.copyDist = dist
+	return 0, false, nil
+}
+
+func (d *deflate64Reader) Read(p []byte) (int, error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+	n := 0
+	for n < len(p) {
+		if d.copyLen > 0 {
+			b := d.window[(d.wpos-d.copyDist)&deflate64WindowMask]
+			d.put(b)
+			p[n] = b
+			n++
+			d.copyLen--
+			continue
+		}
+		if !d.inBlock {
+			if d.final {
+				d.err = io.EOF
+				break
+			}
+			if err := d.readBlockHeader(); err != nil {
+				d.err = err
+				return n, err
+			}
+			continue
+		}
+		b, ok, err := d.step()
+		if err != nil {
+			d.err = err
+			return n, err
+		}
+		if ok {
+			d.put(b)
+			p[n] = b
+			n++
+		}
+	}
+	if n == 0 && d.err != nil {
+		return 0, d.err
+	}
+	return n, nil
+}
+
+func (d *deflate64Reader) Close() error {
+	return nil
+}
@@ -0,0 +1,91 @@
+package uncozip
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+type bitWriter struct {
+	bytes.Buffer
+	bits  uint32
+	nbits uint
+}
+
+func (w *bitWriter) writeBits(v uint32, n uint) {
+	w.bits |= v << w.nbits
+	w.nbits += n
+	for w.nbits >= 8 {
+		w.WriteByte(byte(w.bits))
+		w.bits >>= 8
+		w.nbits -= 8
+	}
+}
+
+// writeCode writes a Huffman code whose most significant bit comes first.
+func (w *bitWriter) writeCode(code uint32, n uint) {
+	w.writeBits(reverseBits(code, n), n)
+}
+
+func (w *bitWriter) flush() {
+	if w.nbits > 0 {
+		w.writeBits(0, 8-w.nbits)
+	}
+}
+
+func TestDeflate64Extension(t *testing.T) {
+	random := make([]byte, 40000)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	var w bitWriter
+	// stored block
+	w.writeBits(0, 1)
+	w.writeBits(0, 2)
+	w.flush()
+	w.writeBits(uint32(len(random)), 16)
+	w.writeBits(^uint32(len(random))&0xFFFF, 16)
+	w.Write(random)
+	// final block with the fixed Huffman codes
+	w.writeBits(1, 1)
+	w.writeBits(1, 2)
+	w.writeCode(0x30+'Z', 8)
+	// length 1000: the code 285 with 16 extra bits
+	w.writeCode(0xC0+285-280, 8)
+	w.writeBits(1000-3, 16)
+	// distance 33000: the code 30 with 14 extra bits
+	w.writeCode(30, 5)
+	w.writeBits(33000-32769, 14)
+	// end of block
+	w.writeCode(0, 7)
+	w.flush()
+
+	expect := append(random, 'Z')
+	start := len(expect) - 33000
+	expect = append(expect, expect[start:start+1000]...)
+
+	output, err := io.ReadAll(newDeflate64Reader(&w.Buffer))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !bytes.Equal(output, expect) {
+		t.Fatalf("output: expect %d bytes, but %d bytes", len(expect), len(output))
+	}
+}
+
+func TestDeflate64CompatibleWithDeflate(t *testing.T) {
+	expect := []byte("Deflate64 is also known as Enhanced Deflate. Deflate64 is also known as Enhanced Deflate.\n")
+	var compressed bytes.Buffer
+	fw, _ := flate.NewWriter(&compressed, flate.BestCompression)
+	fw.Write(expect)
+	fw.Close()
+
+	output, err := io.ReadAll(newDeflate64Reader(&compressed))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !bytes.Equal(output, expect) {
+		t.Fatalf("output: expect '%s', but '%s'", expect, output)
+	}
+}
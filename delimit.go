@@ -0,0 +1,140 @@
+package uncozip
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"sync"
+)
+
+// selfDelimiting returns true when the end of the current entry data can be
+// found by the decompressor without reading any byte after it.
+func (cz *CorruptedZip) selfDelimiting() bool {
+	if (cz.header.Bits & bitEncrypted) != 0 {
+		return false
+	}
+	switch cz.method {
+	case Deflate, Deflate64:
+		return true
+	case LZMA:
+		return (cz.header.Bits & bitLZMAEndMarker) != 0
+	}
+	return false
+}
+
+// readDataDescriptor reads the data descriptor whose form (32-bit or 64-bit)
+// is decided by compressedSize, the size of the compressed data just read.
+// When neither form matches, the form hinted by zip64 is used.
+func readDataDescriptor(br *bufio.Reader, compressedSize int64, zip64 bool) (*_DataDescriptor64, error) {
+	sig, err := br.Peek(sigSize)
+	if err != nil {
+		if err == io.EOF && len(sig) > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if bytes.Equal(sig, sigDataDescriptor) {
+		br.Discard(sigSize)
+	}
+	buffer, err := br.Peek(dataDescriptor64Size)
+	if len(buffer) < dataDescriptorSize {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if len(buffer) >= dataDescriptor64Size {
+		size64 := binary.LittleEndian.Uint64(buffer[4:])
+		size32 := binary.LittleEndian.Uint32(buffer[4:])
+		if int64(size64) == compressedSize && int64(size32) != compressedSize {
+			zip64 = true
+		} else if int64(size32) == compressedSize && int64(size64) != compressedSize {
+			zip64 = false
+		}
+	} else {
+		zip64 = false
+	}
+	dd := parseDataDescriptor(buffer, zip64)
+	br.Discard(dataDescriptorSizeOf(zip64))
+	return dd, nil
+}
+
+type delimitedBody struct {
+	r      io.Reader
+	finish func() error
+}
+
+func (d *delimitedBody) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	if err == io.EOF {
+		if e := d.finish(); e != nil {
+			return n, e
+		}
+	}
+	return n, err
+}
+
+// delimitByDecompressor makes the body of the current entry whose end is
+// found by the decompressor. The data descriptor just after it is read
+// when the body reaches the end.
+func (cz *CorruptedZip) delimitByDecompressor() error {
+	cz.Debug("Delimit the entry data by the decompressor")
+	start := cz.offset()
+	r := cz.decompressor()(cz.br)
+
+	var (
+		once sync.Once
+		dd   *_DataDescriptor64
+		end  int64
+		err  error
+	)
+	body := &delimitedBody{r: r}
+	body.finish = func() error {
+		once.Do(func() {
+			end = cz.offset()
+			compressedSize := end - start
+			r.Close()
+			dd, err = readDataDescriptor(cz.br, compressedSize, cz.zip64)
+			if err != nil {
+				return
+			}
+			if dd.CompressedSize != uint64(compressedSize) {
+				cz.Debug("  CompressedSize in DataDescriptor:", dd.CompressedSize,
+					"but the compressed stream ended at", compressedSize)
+			}
+		})
+		return err
+	}
+	wait := func() *_DataDescriptor64 {
+		if _, e := io.Copy(io.Discard, body); e != nil && err == nil {
+			err = e
+		}
+		if dd == nil {
+			return &_DataDescriptor64{}
+		}
+		return dd
+	}
+	cz.originalSize = func() uint64 { return wait().UncompressedSize }
+	cz.compressedSize = func() uint64 { return wait().CompressedSize }
+	cz.crc32 = func() uint32 { return wait().CRC32 }
+	cz.dataEndOffset = func() int64 {
+		wait()
+		return end
+	}
+	cz.dataDescriptorOffset = func() int64 {
+		if wait(); dd == nil {
+			return -1
+		}
+		return end
+	}
+	cz.bgErr = func() error {
+		wait()
+		return err
+	}
+	cz.closers = append(cz.closers, func() { wait() })
+	cz.nextSignatureAlreadyRead = false
+	cz.rawFileData = body
+	cz.body = body
+	return nil
+}
@@ -0,0 +1,334 @@
+package uncozip
+
+import (
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FS is the file system made from the entries of a CorruptedZip.
+// It implements fs.FS, fs.ReadDirFS, fs.StatFS and fs.ReadFileFS.
+type FS struct {
+	entries map[string]*fsEntry
+}
+
+type fsEntry struct {
+	name     string // the base name
+	header   *FileHeader
+	isDir    bool
+	modTime  time.Time
+	mode     *fs.FileMode // the Unix mode when the archive has it
+	spool    *Spool
+	err      error    // the error returned at the end of the data (ErrChecksum)
+	children []string // the full paths of the children when isDir is true
+}
+
+// NewFS reads all entries of cz and returns the file system of them.
+// The bodies of the entries up to memLimit bytes are kept in memory and
+// the larger ones are spooled to temporary files in tempDir
+// (os.TempDir() when tempDir is ""). Call Close to remove the temporary files.
+// The directories which do not have their own entries are synthesized.
+// The entries whose names are not valid for fs.ValidPath are ignored.
+// When the CRC32 of a file does not match, reading the file returns
+// ErrChecksum at the end of the data.
+func NewFS(cz *CorruptedZip, memLimit int64, tempDir string) (*FS, error) {
+	fsys := &FS{
+		entries: map[string]*fsEntry{
+			".": {name: ".", isDir: true},
+		},
+	}
+	rawNames := map[string]*fsEntry{}
+	for entry := range cz.Each {
+		name := strings.TrimSuffix(path.Clean(entry.Name()), "/")
+		if !fs.ValidPath(name) || name == "." {
+			cz.Debug("NewFS: ignore invalid path:", entry.Name())
+			continue
+		}
+		e := &fsEntry{
+			name:    path.Base(name),
+			isDir:   entry.IsDir(),
+			modTime: entry.LastModificationTime,
+		}
+		if mode, ok := entry.Mode(); ok {
+			e.mode = &mode
+		}
+		if !e.isDir {
+			if err := e.read(entry, memLimit, tempDir); err != nil {
+				fsys.Close()
+				return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+			}
+		}
+		e.header = entry.FileHeader()
+		fsys.add(name, e)
+		rawNames[string(entry.RawName())] = e
+	}
+	if err := cz.Err(); err != nil && err != io.EOF {
+		fsys.Close()
+		return nil, err
+	}
+	// Info-ZIP writes the Unix modes only in the central directory.
+	cd, _ := cz.ReadCentralDirectory()
+	for _, c := range cd {
+		if e, ok := rawNames[string(c.RawName)]; ok && e.mode == nil {
+			if mode, ok := c.Mode(); ok {
+				e.mode = &mode
+			}
+		}
+	}
+	for _, e := range fsys.entries {
+		sort.Strings(e.children)
+	}
+	return fsys, nil
+}
+
+// read spools the body of the entry into e and checks its CRC32.
+func (e *fsEntry) read(cz *CorruptedZip, memLimit int64, tempDir string) error {
+	h := crc32.NewIEEE()
+	s, err := NewSpool(io.TeeReader(cz.Body(), h), memLimit, tempDir)
+	if err != nil {
+		return err
+	}
+	e.spool = s
+	if cz.HasCRC32() && h.Sum32() != cz.CRC32() {
+		e.err = ErrChecksum
+	}
+	return nil
+}
+
+// add registers e as name and synthesizes its parent directories.
+func (fsys *FS) add(name string, e *fsEntry) {
+	if old, ok := fsys.entries[name]; ok {
+		if old.isDir && e.isDir {
+			old.header = e.header
+			old.modTime = e.modTime
+			old.mode = e.mode
+			return
+		}
+		if old.spool != nil {
+			old.spool.Close()
+		}
+		if old.isDir {
+			e.children = old.children
+		}
+		fsys.entries[name] = e
+		return
+	}
+	fsys.entries[name] = e
+	for {
+		parent := path.Dir(name)
+		p, ok := fsys.entries[parent]
+		if !ok {
+			p = &fsEntry{name: path.Base(parent), isDir: true, modTime: e.modTime}
+			fsys.entries[parent] = p
+		}
+		p.children = append(p.children, name)
+		if ok {
+			return
+		}
+		name = parent
+	}
+}
+
+// Close removes the temporary files.
+func (fsys *FS) Close() error {
+	var err error
+	for _, e := range fsys.entries {
+		if e.spool != nil {
+			if err1 := e.spool.Close(); err == nil {
+				err = err1
+			}
+		}
+	}
+	return err
+}
+
+func (fsys *FS) lookup(op, name string) (*fsEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	e, ok := fsys.entries[name]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return e, nil
+}
+
+// Open opens the named file or directory.
+func (fsys *FS) Open(name string) (fs.File, error) {
+	e, err := fsys.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if e.isDir {
+		return &fsDir{fsys: fsys, entry: e}, nil
+	}
+	r, err := e.spool.Open()
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &fsFile{entry: e, reader: r}, nil
+}
+
+// Stat returns the fs.FileInfo of the named file or directory.
+func (fsys *FS) Stat(name string) (fs.FileInfo, error) {
+	e, err := fsys.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// ReadFile returns the contents of the named file.
+func (fsys *FS) ReadFile(name string) ([]byte, error) {
+	e, err := fsys.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if e.isDir {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	data, err := e.spool.Bytes()
+	if err == nil && e.err != nil {
+		err = &fs.PathError{Op: "read", Path: name, Err: e.err}
+	}
+	return data, err
+}
+
+// ReadDir returns the entries of the named directory sorted by filename.
+func (fsys *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	e, err := fsys.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !e.isDir {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	return fsys.dirEntries(e), nil
+}
+
+func (fsys *FS) dirEntries(e *fsEntry) []fs.DirEntry {
+	list := make([]fs.DirEntry, 0, len(e.children))
+	for _, child := range e.children {
+		list = append(list, fs.FileInfoToDirEntry(fsys.entries[child]))
+	}
+	return list
+}
+
+// fsEntry implements fs.FileInfo
+
+func (e *fsEntry) Name() string       { return e.name }
+func (e *fsEntry) ModTime() time.Time { return e.modTime }
+func (e *fsEntry) IsDir() bool        { return e.isDir }
+
+func (e *fsEntry) Size() int64 {
+	if e.spool == nil {
+		return 0
+	}
+	return e.spool.Size()
+}
+
+// Sys returns *FileHeader or nil for the synthesized directories.
+func (e *fsEntry) Sys() any {
+	if e.header == nil {
+		return nil
+	}
+	return e.header
+}
+
+// Mode returns the Unix mode in the archive or the read-only permission.
+func (e *fsEntry) Mode() fs.FileMode {
+	if e.isDir {
+		if e.mode != nil {
+			return fs.ModeDir | (*e.mode &^ fs.ModeType)
+		}
+		return fs.ModeDir | 0555
+	}
+	if e.mode != nil {
+		return *e.mode &^ fs.ModeDir
+	}
+	return 0444
+}
+
+type fsFile struct {
+	entry  *fsEntry
+	reader io.ReadSeekCloser
+}
+
+func (f *fsFile) Stat() (fs.FileInfo, error) {
+	return f.entry, nil
+}
+
+// Read returns the error of the entry (ErrChecksum) instead of io.EOF.
+func (f *fsFile) Read(p []byte) (int, error) {
+	n, err := f.reader.Read(p)
+	if err == io.EOF && f.entry.err != nil {
+		err = f.entry.err
+	}
+	return n, err
+}
+
+func (f *fsFile) Close() error {
+	return f.reader.Close()
+}
+
+// Seek and ReadAt make http.FileServer serve ranges.
+
+func (f *fsFile) Seek(offset int64, whence int) (int64, error) {
+	return f.reader.Seek(offset, whence)
+}
+
+func (f *fsFile) ReadAt(p []byte, offset int64) (int, error) {
+	if r, ok := f.reader.(io.ReaderAt); ok {
+		return r.ReadAt(p, offset)
+	}
+	return 0, fmt.Errorf("%s: %w", f.entry.name, fs.ErrInvalid)
+}
+
+type fsDir struct {
+	fsys    *FS
+	entry   *fsEntry
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *fsDir) Stat() (fs.FileInfo, error) {
+	return d.entry, nil
+}
+
+func (d *fsDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.entry.name, Err: fs.ErrInvalid}
+}
+
+func (d *fsDir) Close() error {
+	return nil
+}
+
+func (d *fsDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if d.entries == nil {
+		d.entries = d.fsys.dirEntries(d.entry)
+	}
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(rest) {
+		n = len(rest)
+	}
+	d.offset += n
+	return rest[:n], nil
+}
+
+var (
+	_ fs.ReadDirFS  = (*FS)(nil)
+	_ fs.StatFS     = (*FS)(nil)
+	_ fs.ReadFileFS = (*FS)(nil)
+)
@@ -0,0 +1,125 @@
+package uncozip
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFS(t *testing.T) {
+	files := map[string]string{
+		"hello.txt":       "hello",
+		"dir/small.txt":   "small",
+		"dir/sub/big.txt": strings.Repeat("big data\n", 100),
+	}
+	var archive bytes.Buffer
+	archive.Write(makeEntry("dir/", Store, nil, 0, 0, false))
+	for _, name := range []string{"hello.txt", "dir/small.txt", "dir/sub/big.txt"} {
+		data := []byte(files[name])
+		archive.Write(makeEntry(name, Store, data, crc32.ChecksumIEEE(data), uint32(len(data)), name == "dir/small.txt"))
+	}
+	archive.Write(sigCentralDirectoryHeader)
+
+	fsys, err := NewFS(New(bytes.NewReader(archive.Bytes())), 64, t.TempDir())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer fsys.Close()
+
+	if err := fstest.TestFS(fsys, "hello.txt", "dir/small.txt", "dir/sub/big.txt", "dir/sub"); err != nil {
+		t.Fatal(err.Error())
+	}
+	for name, expect := range files {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if string(data) != expect {
+			t.Fatalf("%s: expect '%s' but '%s'", name, expect, data)
+		}
+	}
+	if fsys.entries["dir/sub/big.txt"].spool.tempFile == "" {
+		t.Fatal("expect the large file spooled to a temporary file")
+	}
+	if info, err := fs.Stat(fsys, "dir"); err != nil || info.Sys() == nil {
+		t.Fatal("expect the header of the directory entry")
+	}
+	if info, err := fs.Stat(fsys, "dir/sub"); err != nil || !info.IsDir() || info.Sys() != nil {
+		t.Fatal("expect the synthesized directory")
+	}
+}
+
+func TestFSChecksum(t *testing.T) {
+	good := []byte("good")
+	var archive bytes.Buffer
+	archive.Write(makeEntry("broken.txt", Store, []byte("broken"), 0, 6, false))
+	archive.Write(makeEntry("good.txt", Store, good, crc32.ChecksumIEEE(good), uint32(len(good)), false))
+	archive.Write(sigCentralDirectoryHeader)
+
+	fsys, err := NewFS(New(bytes.NewReader(archive.Bytes())), 64, t.TempDir())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer fsys.Close()
+
+	if data, err := fs.ReadFile(fsys, "good.txt"); err != nil || string(data) != "good" {
+		t.Fatalf("good.txt: unexpected '%s' (%v)", data, err)
+	}
+	if _, err := fs.ReadFile(fsys, "broken.txt"); !errors.Is(err, ErrChecksum) {
+		t.Fatalf("ReadFile: expect ErrChecksum, but %v", err)
+	}
+	f, err := fsys.Open("broken.txt")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer f.Close()
+	if data, err := io.ReadAll(f); !errors.Is(err, ErrChecksum) || string(data) != "broken" {
+		t.Fatalf("Read: expect 'broken' and ErrChecksum, but '%s' and %v", data, err)
+	}
+}
+
+func TestFSMode(t *testing.T) {
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	modes := map[string]fs.FileMode{
+		"run.sh": 0755,
+		"bin/":   fs.ModeDir | 0700,
+	}
+	for _, name := range []string{"run.sh", "bin/", "plain.txt"} {
+		h := &zip.FileHeader{Name: name, Method: zip.Deflate}
+		if mode, ok := modes[name]; ok {
+			h.SetMode(mode)
+		}
+		w, err := zw.CreateHeader(h)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		io.WriteString(w, name)
+	}
+	zw.Close()
+
+	fsys, err := NewFS(New(bytes.NewReader(archive.Bytes())), 64, t.TempDir())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer fsys.Close()
+	for name, expect := range map[string]fs.FileMode{
+		"run.sh":    0755,
+		"bin":       fs.ModeDir | 0700,
+		"plain.txt": 0444,
+	} {
+		info, err := fs.Stat(fsys, name)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if info.Mode() != expect {
+			t.Fatalf("%s: expect %v, but %v", name, expect, info.Mode())
+		}
+	}
+}
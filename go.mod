@@ -3,8 +3,10 @@ module github.com/hymkor/uncozip
 go 1.23
 
 require (
+	github.com/klauspost/compress v1.18.0
 	github.com/mattn/go-tty v0.0.4
 	github.com/nyaosorg/go-windows-mbcs v0.4.0
+	github.com/ulikunitz/xz v0.5.15
 	golang.org/x/term v0.4.0
 	golang.org/x/text v0.7.0
 )
@@ -0,0 +1,115 @@
+package uncozip
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// FileHeader is a snapshot of the local file header of an entry.
+// Unlike the methods of CorruptedZip, it is not changed by the next Scan.
+type FileHeader struct {
+	Name          string
+	RawName       []byte // the filename as it is stored in the local file header
+	ReaderVersion uint16
+	Flags         uint16
+
+	// Method is the compression method. For an entry encrypted with
+	// WinZip AES, it is the method written in the extra field.
+	Method uint16
+
+	ModifiedTime uint16 // MS-DOS time
+	ModifiedDate uint16 // MS-DOS date
+
+	Modified time.Time
+	Accessed time.Time
+	Created  time.Time
+
+	CRC32              uint32
+	CompressedSize64   uint64
+	UncompressedSize64 uint64
+
+	// DataDescriptorPending is true when CRC32 and the sizes are left zero
+	// because they are in the data descriptor following the data not read yet.
+	DataDescriptorPending bool
+
+	Extra []ExtraField
+}
+
+// FileHeader returns the snapshot of the header of the most recent file.
+// When an "data descriptor" exists and the file data is not read all by Body
+// or Skip yet, the CRC32 and the sizes are left zero with DataDescriptorPending.
+func (cz *CorruptedZip) FileHeader() *FileHeader {
+	h := &FileHeader{
+		Name:          cz.name,
+		RawName:       bytes.Clone(cz.rawName),
+		ReaderVersion: cz.header.RequiredVersion,
+		Flags:         cz.header.Bits,
+		Method:        cz.method,
+		ModifiedTime:  cz.header.ModifiedTime,
+		ModifiedDate:  cz.header.ModifiedDate,
+		Modified:      cz.LastModificationTime,
+		Accessed:      cz.LastAccessTime,
+		Created:       cz.CreationTime,
+	}
+	if cz.HasDataDescriptor() && !cz.IsDir() && (cz.dataRead == nil || !*cz.dataRead) {
+		h.DataDescriptorPending = true
+	} else {
+		if cz.HasDataDescriptor() {
+			// The rest of the raw data (e.g. the padding after the compressed
+			// stream) has to be read before the data descriptor.
+			cz.Skip()
+		}
+		h.CRC32 = cz.CRC32()
+		h.CompressedSize64 = cz.CompressedSize()
+		h.UncompressedSize64 = cz.OriginalSize()
+	}
+	for _, f := range cz.extraFields {
+		h.Extra = append(h.Extra, ExtraField{ID: f.ID, Data: bytes.Clone(f.Data)})
+	}
+	return h
+}
+
+// IsDir returns true when the entry is a directory.
+func (h *FileHeader) IsDir() bool {
+	return len(h.Name) > 0 && h.Name[len(h.Name)-1] == '/'
+}
+
+// IsEncrypted returns true when the entry is encrypted.
+func (h *FileHeader) IsEncrypted() bool {
+	return (h.Flags & bitEncrypted) != 0
+}
+
+// ZipFileHeader converts the header to archive/zip.FileHeader.
+// The fields which exist only in the central directory (Comment, CreatorVersion
+// and ExternalAttrs) are left zero.
+func (h *FileHeader) ZipFileHeader() *zip.FileHeader {
+	var extra bytes.Buffer
+	for _, f := range h.Extra {
+		binary.Write(&extra, binary.LittleEndian, [2]uint16{f.ID, uint16(len(f.Data))})
+		extra.Write(f.Data)
+	}
+	z := &zip.FileHeader{
+		Name:               h.Name,
+		ReaderVersion:      h.ReaderVersion,
+		Flags:              h.Flags,
+		Method:             h.Method,
+		Modified:           h.Modified,
+		ModifiedTime:       h.ModifiedTime,
+		ModifiedDate:       h.ModifiedDate,
+		CRC32:              h.CRC32,
+		CompressedSize64:   h.CompressedSize64,
+		UncompressedSize64: h.UncompressedSize64,
+		Extra:              extra.Bytes(),
+	}
+	if h.CompressedSize64 > math.MaxUint32 || h.UncompressedSize64 > math.MaxUint32 {
+		z.CompressedSize = math.MaxUint32
+		z.UncompressedSize = math.MaxUint32
+	} else {
+		z.CompressedSize = uint32(h.CompressedSize64)
+		z.UncompressedSize = uint32(h.UncompressedSize64)
+	}
+	return z
+}
@@ -0,0 +1,72 @@
+package uncozip
+
+import (
+	"bytes"
+	"hash/crc32"
+	"io"
+	"testing"
+)
+
+func TestFileHeader(t *testing.T) {
+	data := []byte("first")
+	sum := crc32.ChecksumIEEE(data)
+	var archive bytes.Buffer
+	archive.Write(makeEntry("first.txt", Store, data, sum, uint32(len(data)), true))
+	archive.Write(makeEntry("second.txt", Store, data, sum, uint32(len(data)), false))
+	archive.Write(sigCentralDirectoryHeader)
+
+	cz := New(bytes.NewReader(archive.Bytes()))
+	if !cz.Scan() {
+		t.Fatalf("Scan failed: %v", cz.Err())
+	}
+	io.Copy(io.Discard, cz.Body())
+	h := cz.FileHeader()
+	if !cz.Scan() {
+		t.Fatalf("Scan failed: %v", cz.Err())
+	}
+	if h.Name != "first.txt" || string(h.RawName) != "first.txt" {
+		t.Fatalf("Name: expect 'first.txt' but '%s'", h.Name)
+	}
+	if h.CRC32 != sum || h.UncompressedSize64 != uint64(len(data)) || h.CompressedSize64 != uint64(len(data)) {
+		t.Fatalf("CRC32 and sizes: unexpected %X %d %d", h.CRC32, h.UncompressedSize64, h.CompressedSize64)
+	}
+	if (h.Flags & bitDataDescriptorUsed) == 0 {
+		t.Fatal("Flags: expect the data descriptor bit set")
+	}
+
+	z := h.ZipFileHeader()
+	if z.Name != h.Name || z.CRC32 != sum || z.UncompressedSize64 != uint64(len(data)) || z.Method != Store {
+		t.Fatalf("ZipFileHeader: unexpected %+v", z)
+	}
+	if info := z.FileInfo(); info.Size() != int64(len(data)) || info.IsDir() {
+		t.Fatalf("FileInfo: unexpected size %d", info.Size())
+	}
+}
+
+func TestFileHeaderBeforeBody(t *testing.T) {
+	data := []byte("before the body")
+	sum := crc32.ChecksumIEEE(data)
+	var archive bytes.Buffer
+	archive.Write(makeEntry("dd.txt", Store, data, sum, uint32(len(data)), true))
+	archive.Write(sigCentralDirectoryHeader)
+
+	cz := New(bytes.NewReader(archive.Bytes()))
+	if !cz.Scan() {
+		t.Fatalf("Scan failed: %v", cz.Err())
+	}
+	h := cz.FileHeader()
+	if !h.DataDescriptorPending || h.CRC32 != 0 || h.UncompressedSize64 != 0 {
+		t.Fatalf("expect the pending data descriptor, but %+v", h)
+	}
+	body, err := io.ReadAll(cz.Body())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !bytes.Equal(body, data) {
+		t.Fatalf("body: expect '%s', but '%s'", data, body)
+	}
+	h = cz.FileHeader()
+	if h.DataDescriptorPending || h.CRC32 != sum || h.UncompressedSize64 != uint64(len(data)) {
+		t.Fatalf("expect the values of the data descriptor, but %+v", h)
+	}
+}
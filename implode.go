@@ -0,0 +1,172 @@
+package uncozip
+
+import (
+	"io"
+	"sort"
+)
+
+// Implode - Method 6 is a LZ77 with Shannon-Fano trees.
+// The bit 1 of the general purpose flags selects the 8K sliding
+// dictionary (otherwise 4K) and the bit 2 selects three Shannon-Fano
+// trees (with the literal tree) instead of two.
+
+const (
+	bitImplode8KDictionary = 1 << 1
+	bitImplode3Trees       = 1 << 2
+	implodeMaxBits         = 16
+)
+
+type implodeReader struct {
+	legacyReader
+	dict8K   bool
+	literal  *_HuffmanTable
+	length   *_HuffmanTable
+	distance *_HuffmanTable
+	minMatch int
+	ready    bool
+}
+
+func newImplodeReader(r io.Reader, bits uint16, size int64) io.ReadCloser {
+	z := &implodeReader{
+		dict8K:   (bits & bitImplode8KDictionary) != 0,
+		minMatch: 2,
+	}
+	if (bits & bitImplode3Trees) != 0 {
+		z.literal = &_HuffmanTable{}
+		z.minMatch = 3
+	}
+	z.init(r, size, z.step)
+	return z
+}
+
+// readShannonFanoTree reads a tree of n values and returns its lookup table.
+func (z *implodeReader) readShannonFanoTree(n int) (*_HuffmanTable, error) {
+	size, err := z.getBits(8)
+	if err != nil {
+		return nil, err
+	}
+	lengths := make([]uint8, 0, n)
+	for i := 0; i <= int(size); i++ {
+		v, err := z.getBits(8)
+		if err != nil {
+			return nil, err
+		}
+		bitLength := uint8(v&0x0F) + 1
+		for count := int(v>>4) + 1; count > 0; count-- {
+			if len(lengths) >= n {
+				return nil, ErrLegacyData
+			}
+			lengths = append(lengths, bitLength)
+		}
+	}
+	if len(lengths) != n {
+		return nil, ErrLegacyData
+	}
+	return newShannonFanoTable(lengths)
+}
+
+// newShannonFanoTable makes a lookup table as the APPNOTE.TXT 5.3.7 describes.
+func newShannonFanoTable(lengths []uint8) (*_HuffmanTable, error) {
+	order := make([]int, len(lengths))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return lengths[order[i]] < lengths[order[j]]
+	})
+	codes := make([]uint32, len(lengths))
+	var code, increment uint32
+	var lastBitLength uint8
+	maxBits := uint(0)
+	for i := len(order) - 1; i >= 0; i-- {
+		code += increment
+		bitLength := lengths[order[i]]
+		if bitLength != lastBitLength {
+			lastBitLength = bitLength
+			increment = 1 << (implodeMaxBits - bitLength)
+		}
+		if code >= 1<<implodeMaxBits {
+			return nil, ErrLegacyData
+		}
+		codes[order[i]] = reverseBits(code, implodeMaxBits)
+		if uint(bitLength) > maxBits {
+			maxBits = uint(bitLength)
+		}
+	}
+	h := &_HuffmanTable{
+		table:   make([]uint32, 1<<maxBits),
+		maxBits: maxBits,
+	}
+	for symbol, n := range lengths {
+		for j := codes[symbol]; j < uint32(len(h.table)); j += 1 << n {
+			h.table[j] = uint32(symbol)<<5 | uint32(n)
+		}
+	}
+	return h, nil
+}
+
+func (z *implodeReader) readTrees() (err error) {
+	if z.literal != nil {
+		if z.literal, err = z.readShannonFanoTree(256); err != nil {
+			return err
+		}
+	}
+	if z.length, err = z.readShannonFanoTree(64); err != nil {
+		return err
+	}
+	z.distance, err = z.readShannonFanoTree(64)
+	return err
+}
+
+func (z *implodeReader) step() error {
+	if !z.ready {
+		if err := z.readTrees(); err != nil {
+			return err
+		}
+		z.ready = true
+	}
+	bit, err := z.getBits(1)
+	if err != nil {
+		return err
+	}
+	if bit != 0 {
+		var c uint32
+		if z.literal != nil {
+			c, err = z.decode(z.literal)
+		} else {
+			c, err = z.getBits(8)
+		}
+		if err != nil {
+			return err
+		}
+		z.put(byte(c))
+		return nil
+	}
+	lowBits := uint(6)
+	if z.dict8K {
+		lowBits = 7
+	}
+	low, err := z.getBits(lowBits)
+	if err != nil {
+		return err
+	}
+	high, err := z.decode(z.distance)
+	if err != nil {
+		return err
+	}
+	dist := int(high<<lowBits|low) + 1
+
+	length, err := z.decode(z.length)
+	if err != nil {
+		return err
+	}
+	if length == 63 {
+		more, err := z.getBits(8)
+		if err != nil {
+			return err
+		}
+		length += more
+	}
+	z.copyMatch(dist, int(length)+z.minMatch)
+	return nil
+}
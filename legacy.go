@@ -0,0 +1,101 @@
+package uncozip
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+// The legacy methods of PKZIP 1.x (Shrink, Reduce and Implode) have no
+// end-of-data marker. Their decoders stop when the uncompressed size
+// written in the local file header is reached. When the size is unknown
+// (the data descriptor is used), they stop at the end of the entry data.
+
+const (
+	Shrink  = 1
+	Reduce1 = 2
+	Reduce2 = 3
+	Reduce3 = 4
+	Reduce4 = 5
+	Implode = 6
+)
+
+var ErrLegacyData = errors.New("legacy method: data error")
+
+const (
+	legacyWindowSize = 1 << 13
+	legacyWindowMask = legacyWindowSize - 1
+)
+
+// legacyReader is the common part of the decoders for the legacy methods.
+// The decoder calls put and copyMatch in step to emit the uncompressed bytes.
+type legacyReader struct {
+	_BitReader
+	size    int64
+	total   int64
+	window  [legacyWindowSize]byte
+	pending []byte
+	start   int
+	step    func() error
+	err     error
+}
+
+func (lr *legacyReader) init(r io.Reader, size int64, step func() error) {
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	lr.br = br
+	lr.size = size
+	lr.step = step
+}
+
+func (lr *legacyReader) put(b byte) {
+	if lr.size >= 0 && lr.total >= lr.size {
+		return
+	}
+	lr.window[lr.total&legacyWindowMask] = b
+	lr.total++
+	lr.pending = append(lr.pending, b)
+}
+
+// copyMatch copies length bytes from dist bytes ago.
+// The area before the start of the output is treated as zeros.
+func (lr *legacyReader) copyMatch(dist, length int) {
+	for ; length > 0; length-- {
+		var b byte
+		if int64(dist) <= lr.total {
+			b = lr.window[(lr.total-int64(dist))&legacyWindowMask]
+		}
+		lr.put(b)
+	}
+}
+
+func (lr *legacyReader) Read(p []byte) (int, error) {
+	if lr.start >= len(lr.pending) {
+		lr.start = 0
+		lr.pending = lr.pending[:0]
+	}
+	for len(lr.pending) <= 0 {
+		if lr.err != nil {
+			return 0, lr.err
+		}
+		if lr.size >= 0 && lr.total >= lr.size {
+			lr.err = io.EOF
+			continue
+		}
+		if err := lr.step(); err != nil {
+			if lr.size < 0 && (err == io.ErrUnexpectedEOF || err == io.EOF) {
+				err = io.EOF
+			}
+			lr.err = err
+		}
+	}
+	n := copy(p, lr.pending[lr.start:])
+	lr.start += n
+	return n, nil
+}
+
+func (lr *legacyReader) Close() error {
+	return nil
+}
@@ -0,0 +1,214 @@
+package uncozip
+
+import (
+	"bytes"
+	"hash/crc32"
+	"io"
+	"strings"
+	"testing"
+)
+
+// shrinkForTest is a minimal Shrink encoder without partial clearing.
+func shrinkForTest(data []byte) []byte {
+	var w bitWriter
+	codeSize := uint(shrinkMinCodeSize)
+	table := map[string]uint32{}
+	for i := 0; i < 256; i++ {
+		table[string([]byte{byte(i)})] = uint32(i)
+	}
+	nextCode := uint32(shrinkControlCode + 1)
+	emit := func(code uint32) {
+		for code >= 1<<codeSize {
+			w.writeBits(shrinkControlCode, codeSize)
+			w.writeBits(shrinkIncCodeSize, codeSize)
+			codeSize++
+		}
+		w.writeBits(code, codeSize)
+	}
+	current := ""
+	for _, c := range data {
+		next := current + string([]byte{c})
+		if _, ok := table[next]; ok {
+			current = next
+			continue
+		}
+		emit(table[current])
+		if nextCode <= shrinkMaxCode {
+			table[next] = nextCode
+			nextCode++
+		}
+		current = string([]byte{c})
+	}
+	if current != "" {
+		emit(table[current])
+	}
+	w.flush()
+	return w.Bytes()
+}
+
+func testLegacy(t *testing.T, r io.Reader, expect []byte) {
+	t.Helper()
+	output, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !bytes.Equal(output, expect) {
+		t.Fatalf("output: expect '%s', but '%s'", expect, output)
+	}
+}
+
+func TestShrink(t *testing.T) {
+	var source strings.Builder
+	for i := 0; i < 300; i++ {
+		source.WriteString("Shrink is a dynamic LZW. ")
+		source.WriteByte(byte('A' + i%26))
+		source.WriteByte(byte('a' + i%7))
+	}
+	expect := []byte(source.String())
+	data := shrinkForTest(expect)
+	testLegacy(t, newShrinkReader(bytes.NewReader(data), 0, int64(len(expect))), expect)
+	testLegacy(t, newShrinkReader(bytes.NewReader(data), 0, -1), expect)
+}
+
+func TestReduce(t *testing.T) {
+	var w bitWriter
+	// follower sets from 255 down to 0: only 'a' has a follower 'b'
+	for j := 255; j >= 0; j-- {
+		if j == 'a' {
+			w.writeBits(1, 6)
+			w.writeBits('b', 8)
+		} else {
+			w.writeBits(0, 6)
+		}
+	}
+	char := func(c byte, afterA bool) {
+		if afterA {
+			if c == 'b' {
+				w.writeBits(0, 1)
+				w.writeBits(0, 1)
+				return
+			}
+			w.writeBits(1, 1)
+		}
+		w.writeBits(uint32(c), 8)
+	}
+	char('a', false)
+	char('b', true)
+	char('c', false)
+	// the match of the length 4 and the distance 3 (factor 1)
+	char(reduceDLE, false)
+	char(4-3, false)
+	char(3-1, false)
+	// the literal DLE
+	char(reduceDLE, false)
+	char(0, false)
+	w.flush()
+
+	expect := []byte("abcabca\x90")
+	testLegacy(t, newReduceReader(Reduce1)(bytes.NewReader(w.Bytes()), 0, int64(len(expect))), expect)
+}
+
+// writeFlatTree writes a Shannon-Fano tree where all n values have the same bit length.
+func writeFlatTree(w *bitWriter, n int, bitLength uint) {
+	w.writeBits(uint32(n/16-1), 8)
+	for i := 0; i < n/16; i++ {
+		w.writeBits(15<<4|uint32(bitLength-1), 8)
+	}
+}
+
+func TestImplode(t *testing.T) {
+	for _, bits := range []uint16{0, bitImplode8KDictionary | bitImplode3Trees} {
+		var w bitWriter
+		minMatch := 2
+		if (bits & bitImplode3Trees) != 0 {
+			writeFlatTree(&w, 256, 8)
+			minMatch = 3
+		}
+		writeFlatTree(&w, 64, 6)
+		writeFlatTree(&w, 64, 6)
+
+		// With the flat tree, the value v has the code (n-1-v).
+		literal := func(c byte) {
+			w.writeBits(1, 1)
+			if (bits & bitImplode3Trees) != 0 {
+				w.writeCode(uint32(255-c), 8)
+			} else {
+				w.writeBits(uint32(c), 8)
+			}
+		}
+		for _, c := range []byte("implode ") {
+			literal(c)
+		}
+		// the match of the length 10 and the distance 8
+		lowBits := uint(6)
+		if (bits & bitImplode8KDictionary) != 0 {
+			lowBits = 7
+		}
+		dist := uint32(8 - 1)
+		w.writeBits(0, 1)
+		w.writeBits(dist&(1<<lowBits-1), lowBits)
+		w.writeCode(63-dist>>lowBits, 6)
+		w.writeCode(uint32(63-(10-minMatch)), 6)
+		literal('!')
+		w.flush()
+
+		expect := []byte("implode implode im!")
+		testLegacy(t, newImplodeReader(bytes.NewReader(w.Bytes()), bits, int64(len(expect))), expect)
+	}
+}
+
+// The following streams are cross-checked with Info-ZIP UnZip 6.0
+// (unzip -t) except Reduce, which UnZip does not support any longer.
+
+// shrinkPartialClearData clears the table partially (the code 256 and 2)
+// three times.
+var shrinkPartialClearData = []byte("\x53\xd0\xc8\x49\xe3\x66\x0d\x88\x34\x73\x40\xd0\x41\x53\x06\x04\x99\x3c\x6e\xc2\xb4\x49\x03\x50\xc0\x18\x10\x4c\xb4\x5c\x01\xf1\xc6\x0c\x08\x28\x4b\xb4\x24\x81\x02\x22\x86\x0b\x3c\x2e\x40\x4c\x41\x23\x27\x8d\x1b\x80\x02\xd6\x80\x48\x33\x07\x04\x1d\x34\x65\x40\x90\xc9\xe3\x26\x4c\x9b\x34\x63\x40\x30\xd1\x72\x05\xc4\x1b\x33\x20\xa0\x00\x14\xb0\x44\x4b\x12\x28\x20\x62\xb8\xc0\xe3\x02\xc4\x14\x34\x72\xd2\xb8\x59\x03\x22\xcd\x1c\x10\x74\xd0\x94\x01\x41\x26\x8f\x9b\x30\x6d\xd2\x8c\x01\xc1\x44\xcb\x15\x10\x6f\xcc\x80\x08\x38\xb0\xe0\xc1\x84\x20\x82\x98\xa1\x53\x46\x4e\xc5\x8b\x20\xe0\x84\x91\x43\x27\x4d\x18\x36\x20\xc6\xb0\x29\x83\xf3\xe1\x19\x16\x34\x31\x8e\x79\x43\xa6\x0c\xc5\x3b\x68\x3e\xa2\x01\x81\x13\xa3\x9b\x37\x74\x6c\xca\x29\x63\x26\x0d\x9e\xa5\x51\xb1\x82\xc0\x5a\x67\x4e\x19\x32\x0a\x5f\xc6\x9c\x69\x11\xe3\xcd\x9c\x3b\x7b\xfe\x0c\xea\xd0\x0d\x51\xa3\x3e\x93\x76\x6d\xfa\xd4\xeb\xd4\xaa\x57\xb3\x6e\xed\x2a\x15\x6c\x19\xb1\x64\xcd\xc2\x94\x59\x97\xad\x4e\x9e\x3e\x81\x0a\x9d\x5b\x54\xad\x5d\xa5\x4c\x9d\x8e\x81\x2a\x98\xaa\x55\x38\x58\xb5\x72\xa5\x28\x38\xec\xd8\xb2\x51\xc5\x8c\x09\x73\x3a\x35\x6a\xd5\xaa\x4f\x83\xc0\x93\x47\x4f\xec\xd9\xb2\x69\x2b\x00")
+
+var shrinkPartialClearExpect = strings.Repeat("Shrink is the dynamic LZW of PKZIP 1.x. ", 3) +
+	strings.Repeat("After the partial clearing, the codes which are not prefixes are reused. ", 3) +
+	"abcabcabcabcabcabc xyzxyzxyzxyz\n"
+
+// implodeData has the Shannon-Fano trees of various bit lengths
+// with the 4K dictionary and two trees.
+var implodeData = []byte("\x0d\x00\x03\x08\x02\x18\x04\x08\xf7\xf7\xf7\x37\x04\x17\x04\x08\x00\x02\x07\x03\xf7\xf7\x87\xf6\x26\x93\xb6\x85\xcb\xf6\x2d\xd9\xb2\x20\xd3\xce\x05\x49\x17\x8d\x71\xa6\x5a\x6f\xde\x04\xf9\xd6\x2c\x48\xa8\x4b\xb5\x26\x85\x0a\x32\xa6\x4b\xbc\x20\xef\xa6\xb9\xa6\xf5\xa9\x68\xc3\xba\x75\xfb\xd6\x6d\x4b\x23\xc6\xdf\x40\x97\x5b\xb6\xec\x5c\x97\x0a\xa9\x5c\x35\x1b\xf0\x65\xe5\x86\x65\x3b\x97\x45\xd9\xd9\x96\x75\x7b\xe6\x18\xe3\xc8\x93\x79\x3a\x19\xcc\xd3\x59\x3e\x86\x3d\x08\xff\x76\x9c\x49\x75\x5a\x39\xa0\xbf\xeb\x26\xb0\xdc\x03\xc8\x6d\x2a\xc0\x55\x00")
+
+// implode8K3TreesData is the same as implodeData but with the 8K
+// dictionary and three trees.
+var implode8K3TreesData = []byte("\x38\x99\x05\xf9\x49\x03\xa9\x16\x05\x19\x06\x49\x05\x49\x06\x79\x06\x19\x05\x09\x16\x29\x05\x19\x16\x49\x05\x59\x03\x09\x06\x05\x03\x16\x14\x19\x04\x06\x03\x04\x06\x09\x04\x13\x09\x06\x05\x06\xf9\xf9\xf9\xf9\xf9\xf9\xf9\xf9\x39\x28\x0b\x13\x01\x17\x03\x17\xf6\xf6\xf6\x26\x03\x26\x03\x06\x00\x03\xb7\xf6\xf6\xf6\x16\xad\xfc\xbc\x71\xa8\xdf\x3f\xed\xbf\xe6\xfb\x4b\x1b\xed\xf6\x1f\x0a\x7f\xb3\xac\xd1\x6a\xfe\xca\x4e\xce\xaf\x4d\x6b\xa6\x79\xc9\xfc\xce\x19\x4e\x55\xf9\x3b\xc3\x5f\xfd\xbd\xbb\xd3\x2d\x76\xd9\xe3\xb4\x6e\xff\xc6\x5d\xad\xf4\xf1\x9e\x82\x35\xef\xff\x4e\xdd\xe3\xf5\x69\xaf\x77\x8a\xee\xfe\xf3\xcb\xbd\x24\x6b\xea\xff\x50\x3b\x82\xb3\x02\xa0\xd7\x74\xe1\x3d\x00")
+
+var implodeExpect = "Implode is the LZ77 of PKZIP 1.x with the Shannon-Fano trees.\n" +
+	"The literals, the lengths and the distances have their own trees.\n" +
+	strings.Repeat("=", 120) + "\n" +
+	"Implode is the LZ77 of PKZIP 1.x with the Shannon-Fano trees.\n"
+
+// reduce4Data is made from APPNOTE.TXT 5.2 with the follower sets, the
+// extra length byte, the distance over 256 and the literal DLE.
+var reduce4Data = []byte("\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x10\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x14\x00\x01\x0f\x1a\x1f\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x20\x8c\xc9\x11\x68\x69\x20\x6f\x05\x08\xdd\x5c\x9a\x0b\xf1\x56\x06\x39\x07\x04\x72\x86\x58\x9b\x9b\x59\x9d\x9c\x00\x42\x16\xc0\x05\x69\x00\x40\xcc\xb1\x8d\xbd\x09\x65\x61\xc0\x00\xf2\x16\x36\x80\x90\xcd\x09\x75\x20\x44\x19\xc8\x1b\x9d\x10\x96\x46\x88\xb9\xcd\x8d\x01\x00\x00\x00\x40\x90\x04\x00\x00\x00\x00\x40\x50\x06\x08\x4b\x20\x00\x00\x04\x45\x81\x16\x20\x40\xd1\x01\x00\x10\xb8\x04\x4c\x00\x00\x00\x00\x00\x00\x00\x10\x80\x04\x44\x00\x00\x00\x00\x20\xb8\x28\x04\x2e\x00\xa1\x90\x03\x03\x12\x40\x06\x31\x00\x00\x00\x00\x00\x00\x00\x00\x30\x69\x74\x70\x63\x6f\x50\x30\x61\x31\x90\x68\x66\x01\x0b\x00\x00\x40\x00\x00\x00\x00\x00\x00\x00\x00\x00\x41\x2d\x00\x00\xc0\xd0\x92\x04\x09\x00\x00\x00\x00\x00\x10\xe0\x10\x52\x90\x0a\x66\x40\x00\x00\x80\x00\x20\x00\x80\x02\x22\xca\x50\x40\x24\x33\x81\x0c\x0a\x40\x86\x70\x52\x08\xb1\x04\x09\x00\x00\x04\x94\xa4\x12\xac\x50\x1a\xa6\x6d\x35\x82\x08\x02")
+
+var reduce4Expect = "Reduce is the probabilistic compression of PKZIP 0.9 and 1.0. \x90 is DLE.\n" +
+	strings.Repeat("-", 200) + "\n" +
+	"It has four compression factors.\n" +
+	"Reduce is the probabilistic compression of PKZIP 0.9 and 1.0. \n"
+
+func TestLegacyStreams(t *testing.T) {
+	for _, c := range []struct {
+		method uint16
+		bits   uint16
+		data   []byte
+		expect string
+	}{
+		{Shrink, 0, shrinkPartialClearData, shrinkPartialClearExpect},
+		{Implode, 0, implodeData, implodeExpect},
+		{Implode, bitImplode8KDictionary | bitImplode3Trees, implode8K3TreesData, implodeExpect},
+		{Reduce4, 0, reduce4Data, reduce4Expect},
+	} {
+		sum := crc32.ChecksumIEEE([]byte(c.expect))
+		entry := makeEntry("legacy.txt", c.method, c.data, sum, uint32(len(c.expect)), false)
+		entry[6] |= byte(c.bits)
+
+		var archive bytes.Buffer
+		archive.Write(entry)
+		archive.Write(sigCentralDirectoryHeader)
+		testReadEntry(t, archive.Bytes(), c.expect)
+	}
+}
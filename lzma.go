@@ -0,0 +1,480 @@
+package uncozip
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// https://pkware.cachefly.net/webdocs/APPNOTE/APPNOTE-6.3.9.TXT
+// 5.8 LZMA - Method 14
+//
+// The data of an entry starts with the 4-byte header (the version of
+// the LZMA SDK and the size of the properties), the 5-byte properties
+// and the raw LZMA stream. When the bit 1 of the general purpose flags
+// is set, the stream ends with the end-of-stream marker.
+
+const bitLZMAEndMarker = 1 << 1
+
+var ErrLZMAData = errors.New("lzma: data error")
+
+const (
+	lzmaNumStates          = 12
+	lzmaNumPosBitsMax      = 4
+	lzmaNumLenToPosStates  = 4
+	lzmaNumAlignBits       = 4
+	lzmaStartPosModelIndex = 4
+	lzmaEndPosModelIndex   = 14
+	lzmaNumFullDistances   = 1 << (lzmaEndPosModelIndex >> 1)
+	lzmaMatchMinLen        = 2
+	lzmaProbInit           = 1 << 10
+	lzmaMinDictSize        = 1 << 12
+)
+
+type _RangeDecoder struct {
+	br    *bufio.Reader
+	rng   uint32
+	code  uint32
+	err   error
+	ready bool
+}
+
+func (rc *_RangeDecoder) init() error {
+	var buffer [5]byte
+	if _, err := io.ReadFull(rc.br, buffer[:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if buffer[0] != 0 {
+		return ErrLZMAData
+	}
+	rc.rng = 0xFFFFFFFF
+	rc.code = binary.BigEndian.Uint32(buffer[1:])
+	if rc.code == rc.rng {
+		return ErrLZMAData
+	}
+	rc.ready = true
+	return nil
+}
+
+func (rc *_RangeDecoder) normalize() {
+	if rc.rng >= 1<<24 {
+		return
+	}
+	b, err := rc.br.ReadByte()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if rc.err == nil {
+			rc.err = err
+		}
+	}
+	rc.rng <<= 8
+	rc.code = (rc.code << 8) | uint32(b)
+}
+
+// atEnd reports whether the stream seems to be finished without
+// the end-of-stream marker: all input is consumed and the code is zero.
+func (rc *_RangeDecoder) atEnd() bool {
+	if rc.code != 0 {
+		return false
+	}
+	_, err := rc.br.Peek(1)
+	return err == io.EOF
+}
+
+func (rc *_RangeDecoder) decodeBit(prob *uint16) uint32 {
+	bound := (rc.rng >> 11) * uint32(*prob)
+	var bit uint32
+	if rc.code < bound {
+		rc.rng = bound
+		*prob += (1<<11 - *prob) >> 5
+	} else {
+		rc.rng -= bound
+		rc.code -= bound
+		*prob -= *prob >> 5
+		bit = 1
+	}
+	rc.normalize()
+	return bit
+}
+
+func (rc *_RangeDecoder) decodeDirectBits(n int) uint32 {
+	var result uint32
+	for ; n > 0; n-- {
+		rc.rng >>= 1
+		var bit uint32
+		if rc.code >= rc.rng {
+			rc.code -= rc.rng
+			bit = 1
+		}
+		result = (result << 1) | bit
+		rc.normalize()
+	}
+	return result
+}
+
+func (rc *_RangeDecoder) bitTree(probs []uint16, numBits int) uint32 {
+	m := uint32(1)
+	for i := 0; i < numBits; i++ {
+		m = (m << 1) | rc.decodeBit(&probs[m])
+	}
+	return m - (1 << numBits)
+}
+
+func (rc *_RangeDecoder) reverseBitTree(probs []uint16, numBits int) uint32 {
+	m := uint32(1)
+	var symbol uint32
+	for i := 0; i < numBits; i++ {
+		bit := rc.decodeBit(&probs[m])
+		m = (m << 1) | bit
+		symbol |= bit << i
+	}
+	return symbol
+}
+
+func newProbs(n int) []uint16 {
+	probs := make([]uint16, n)
+	for i := range probs {
+		probs[i] = lzmaProbInit
+	}
+	return probs
+}
+
+type _LZMALenDecoder struct {
+	choice  uint16
+	choice2 uint16
+	low     [1 << lzmaNumPosBitsMax][]uint16
+	mid     [1 << lzmaNumPosBitsMax][]uint16
+	high    []uint16
+}
+
+func newLZMALenDecoder() *_LZMALenDecoder {
+	d := &_LZMALenDecoder{
+		choice:  lzmaProbInit,
+		choice2: lzmaProbInit,
+		high:    newProbs(1 << 8),
+	}
+	for i := range d.low {
+		d.low[i] = newProbs(1 << 3)
+		d.mid[i] = newProbs(1 << 3)
+	}
+	return d
+}
+
+func (d *_LZMALenDecoder) decode(rc *_RangeDecoder, posState uint32) uint32 {
+	if rc.decodeBit(&d.choice) == 0 {
+		return rc.bitTree(d.low[posState], 3)
+	}
+	if rc.decodeBit(&d.choice2) == 0 {
+		return 8 + rc.bitTree(d.mid[posState], 3)
+	}
+	return 16 + rc.bitTree(d.high, 8)
+}
+
+type lzmaReader struct {
+	rc       _RangeDecoder
+	lc       uint
+	lp       uint
+	pb       uint
+	dict     []byte
+	dictSize int
+	dictPos  int
+	total    int64
+	size     int64
+	eos      bool
+	state    uint32
+	rep      [4]uint32
+	remain   int
+	finished bool
+	err      error
+
+	literal   []uint16
+	isMatch   []uint16
+	isRep     []uint16
+	isRepG0   []uint16
+	isRepG1   []uint16
+	isRepG2   []uint16
+	isRep0Len []uint16
+	posSlot   [lzmaNumLenToPosStates][]uint16
+	posCoders []uint16
+	align     []uint16
+	lenCoder  *_LZMALenDecoder
+	repLen    *_LZMALenDecoder
+}
+
+func (z *lzmaReader) readHeader(size int64) error {
+	var header struct {
+		Major     byte
+		Minor     byte
+		PropsSize uint16
+	}
+	if err := binary.Read(z.rc.br, binary.LittleEndian, &header); err != nil {
+		return fmt.Errorf("lzma: header: %w", err)
+	}
+	if header.PropsSize < 5 {
+		return fmt.Errorf("lzma: properties size(%d) too small", header.PropsSize)
+	}
+	props := make([]byte, header.PropsSize)
+	if _, err := io.ReadFull(z.rc.br, props); err != nil {
+		return fmt.Errorf("lzma: properties: %w", err)
+	}
+	d := uint(props[0])
+	if d >= 9*5*5 {
+		return fmt.Errorf("lzma: invalid properties(%d)", d)
+	}
+	z.lc = d % 9
+	d /= 9
+	z.lp = d % 5
+	z.pb = d / 5
+
+	dictSize := int64(binary.LittleEndian.Uint32(props[1:5]))
+	if size >= 0 && size < dictSize {
+		dictSize = size
+	}
+	if dictSize < lzmaMinDictSize {
+		dictSize = lzmaMinDictSize
+	}
+	// The dictionary grows up to dictSize as the output grows,
+	// so that a broken header does not allocate huge memory at once.
+	z.dictSize = int(dictSize)
+	return nil
+}
+
+func newLZMAReader(r io.Reader, bits uint16, size int64) io.ReadCloser {
+	z := &lzmaReader{
+		size:      size,
+		eos:       (bits & bitLZMAEndMarker) != 0,
+		isMatch:   newProbs(lzmaNumStates << lzmaNumPosBitsMax),
+		isRep:     newProbs(lzmaNumStates),
+		isRepG0:   newProbs(lzmaNumStates),
+		isRepG1:   newProbs(lzmaNumStates),
+		isRepG2:   newProbs(lzmaNumStates),
+		isRep0Len: newProbs(lzmaNumStates << lzmaNumPosBitsMax),
+		posCoders: newProbs(1 + lzmaNumFullDistances - lzmaEndPosModelIndex),
+		align:     newProbs(1 << lzmaNumAlignBits),
+		lenCoder:  newLZMALenDecoder(),
+		repLen:    newLZMALenDecoder(),
+	}
+	z.rc.br = bufio.NewReader(r)
+	for i := range z.posSlot {
+		z.posSlot[i] = newProbs(1 << 6)
+	}
+	if err := z.readHeader(size); err != nil {
+		z.err = err
+		return z
+	}
+	z.literal = newProbs(0x300 << (z.lc + z.lp))
+	return z
+}
+
+func (z *lzmaReader) getByte(dist uint32) byte {
+	i := z.dictPos - int(dist) - 1
+	if i < 0 {
+		i += len(z.dict)
+	}
+	return z.dict[i]
+}
+
+func (z *lzmaReader) putByte(b byte) {
+	if len(z.dict) < z.dictSize {
+		z.dict = append(z.dict, b)
+		z.dictPos = len(z.dict)
+	} else {
+		z.dict[z.dictPos] = b
+		z.dictPos++
+	}
+	if z.dictPos >= z.dictSize {
+		z.dictPos = 0
+	}
+	z.total++
+}
+
+func (z *lzmaReader) decodeLiteral() byte {
+	var prevByte byte
+	if z.total > 0 {
+		prevByte = z.getByte(0)
+	}
+	litState := ((uint32(z.total) & ((1 << z.lp) - 1)) << z.lc) + (uint32(prevByte) >> (8 - z.lc))
+	probs := z.literal[0x300*litState:]
+
+	symbol := uint32(1)
+	if z.state >= 7 {
+		matchByte := uint32(z.getByte(z.rep[0]))
+		for symbol < 0x100 {
+			matchBit := (matchByte >> 7) & 1
+			matchByte <<= 1
+			bit := z.rc.decodeBit(&probs[((1+matchBit)<<8)+symbol])
+			symbol = (symbol << 1) | bit
+			if matchBit != bit {
+				break
+			}
+		}
+	}
+	for symbol < 0x100 {
+		symbol = (symbol << 1) | z.rc.decodeBit(&probs[symbol])
+	}
+	return byte(symbol - 0x100)
+}
+
+func (z *lzmaReader) decodeDistance(length uint32) uint32 {
+	lenState := length
+	if lenState > lzmaNumLenToPosStates-1 {
+		lenState = lzmaNumLenToPosStates - 1
+	}
+	posSlot := z.rc.bitTree(z.posSlot[lenState], 6)
+	if posSlot < lzmaStartPosModelIndex {
+		return posSlot
+	}
+	numDirectBits := int(posSlot>>1) - 1
+	dist := (2 | (posSlot & 1)) << numDirectBits
+	if posSlot < lzmaEndPosModelIndex {
+		return dist + z.rc.reverseBitTree(z.posCoders[dist-posSlot:], numDirectBits)
+	}
+	dist += z.rc.decodeDirectBits(numDirectBits-lzmaNumAlignBits) << lzmaNumAlignBits
+	return dist + z.rc.reverseBitTree(z.align, lzmaNumAlignBits)
+}
+
+func (z *lzmaReader) validDistance(dist uint32) bool {
+	return int64(dist) < z.total && int(dist) < len(z.dict)
+}
+
+// decodeSymbol decodes a literal or a match. A literal is put into the
+// dictionary and returned with true. A match sets z.remain.
+func (z *lzmaReader) decodeSymbol() (byte, bool, error) {
+	posState := uint32(z.total) & ((1 << z.pb) - 1)
+	state2 := (z.state << lzmaNumPosBitsMax) + posState
+
+	if z.rc.decodeBit(&z.isMatch[state2]) == 0 {
+		b := z.decodeLiteral()
+		switch {
+		case z.state < 4:
+			z.state = 0
+		case z.state < 10:
+			z.state -= 3
+		default:
+			z.state -= 6
+		}
+		z.putByte(b)
+		return b, true, nil
+	}
+	var length uint32
+	if z.rc.decodeBit(&z.isRep[z.state]) == 0 {
+		length = z.lenCoder.decode(&z.rc, posState)
+		if z.state < 7 {
+			z.state = 7
+		} else {
+			z.state = 10
+		}
+		dist := z.decodeDistance(length)
+		if dist == 0xFFFFFFFF {
+			z.finished = true
+			return 0, false, nil
+		}
+		z.rep[3], z.rep[2], z.rep[1], z.rep[0] = z.rep[2], z.rep[1], z.rep[0], dist
+	} else {
+		if z.total == 0 {
+			return 0, false, ErrLZMAData
+		}
+		if z.rc.decodeBit(&z.isRepG0[z.state]) == 0 {
+			if z.rc.decodeBit(&z.isRep0Len[state2]) == 0 {
+				if z.state < 7 {
+					z.state = 9
+				} else {
+					z.state = 11
+				}
+				b := z.getByte(z.rep[0])
+				z.putByte(b)
+				return b, true, nil
+			}
+		} else {
+			var dist uint32
+			if z.rc.decodeBit(&z.isRepG1[z.state]) == 0 {
+				dist = z.rep[1]
+			} else {
+				if z.rc.decodeBit(&z.isRepG2[z.state]) == 0 {
+					dist = z.rep[2]
+				} else {
+					dist = z.rep[3]
+					z.rep[3] = z.rep[2]
+				}
+				z.rep[2] = z.rep[1]
+			}
+			z.rep[1] = z.rep[0]
+			z.rep[0] = dist
+		}
+		length = z.repLen.decode(&z.rc, posState)
+		if z.state < 7 {
+			z.state = 8
+		} else {
+			z.state = 11
+		}
+	}
+	if !z.validDistance(z.rep[0]) {
+		return 0, false, ErrLZMAData
+	}
+	z.remain = int(length) + lzmaMatchMinLen
+	return 0, false, nil
+}
+
+func (z *lzmaReader) Read(p []byte) (int, error) {
+	if z.err != nil {
+		return 0, z.err
+	}
+	if !z.rc.ready {
+		if z.err = z.rc.init(); z.err != nil {
+			return 0, z.err
+		}
+	}
+	n := 0
+	for n < len(p) {
+		if z.size >= 0 && z.total >= z.size {
+			z.finished = true
+		}
+		if z.remain > 0 {
+			if z.finished {
+				z.remain = 0
+				break
+			}
+			b := z.getByte(z.rep[0])
+			z.putByte(b)
+			p[n] = b
+			n++
+			z.remain--
+			continue
+		}
+		if z.finished {
+			break
+		}
+		if z.size < 0 && !z.eos && z.rc.atEnd() {
+			z.finished = true
+			break
+		}
+		b, ok, err := z.decodeSymbol()
+		if err == nil {
+			err = z.rc.err
+		}
+		if err != nil {
+			z.err = err
+			return n, err
+		}
+		if ok {
+			p[n] = b
+			n++
+		}
+	}
+	if n == 0 && z.finished {
+		z.err = io.EOF
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+func (z *lzmaReader) Close() error {
+	return nil
+}
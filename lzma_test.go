@@ -0,0 +1,51 @@
+package uncozip
+
+import (
+	"bytes"
+	"hash/crc32"
+	"strings"
+	"testing"
+)
+
+// lzmaData is made by Python's lzma module with FORMAT_ALONE
+// (with the end-of-stream marker) and converted to the ZIP flavour.
+var lzmaData = []byte("\x09\x14\x05\x00\x5d\x00\x00\x80\x00\x00\x26\x16\x85\xbc\x45\xf0\xfa\x24\x9d\xa9\x85\x91\x57\xc0\x13\x32\xa3\xba\x1e\x75\x05\x05\xc1\x1a\x2c\xb0\x41\xfe\x02\x44\xfa\x33\x98\xe2\x26\x2e\xe1\x33\xb3\xdf\x39\x08\x4b\x60\x75\x21\x6e\x06\x03\xa4\xbc\xea\x89\x58\x93\xcd\x40\x70\xcc\xac\x30\xcd\xec\xc9\x19\xf1\x7f\xff\xd1\xe0\x00\x00")
+
+var lzmaExpect = strings.Repeat("LZMA is the Lempel-Ziv-Markov chain algorithm.\n", 3) +
+	strings.Repeat("abracadabra ", 5) + "\n"
+
+func TestLZMA(t *testing.T) {
+	sum := crc32.ChecksumIEEE([]byte(lzmaExpect))
+	for _, dd := range []bool{false, true} {
+		entry := makeEntry("lzma.txt", LZMA, lzmaData, sum, uint32(len(lzmaExpect)), dd)
+		// set the bit of the end-of-stream marker in the general purpose flags
+		entry[6] |= bitLZMAEndMarker
+
+		var archive bytes.Buffer
+		archive.Write(entry)
+		archive.Write(sigCentralDirectoryHeader)
+		testReadEntry(t, archive.Bytes(), lzmaExpect)
+	}
+}
+
+// lzmaNoMarkerData is made by github.com/ulikunitz/xz/lzma with the known
+// size and without the end-of-stream marker, and converted to the ZIP flavour.
+var lzmaNoMarkerData = []byte("\x09\x14\x05\x00\x5d\x00\x10\x00\x00\x00\x27\x1b\xc0\x06\x53\xae\x63\x57\x67\x2e\x8f\x43\x82\x20\xa5\x80\x8b\x6f\xad\x9a\xe4\x26\x87\x49\xe9\xe9\x09\x9b\xbc\x08\x92\xc6\x9a\x04\x96\x7c\x99\x9a\x56\xd0\x70\xfe\x46\x06\xe1\x19\x7d\x71\x0f\x74\xca\x36\x76\xa7\x7a\x31\x80")
+
+var lzmaNoMarkerExpect = strings.Repeat("No end marker: the size is in the header.\n", 4) +
+	strings.Repeat("mississippi ", 6) + "\n"
+
+// TestLZMANoMarker tests the stream without the end-of-stream marker.
+// With the data descriptor, the size is unknown while decoding and
+// the end is found by the consumed input.
+func TestLZMANoMarker(t *testing.T) {
+	sum := crc32.ChecksumIEEE([]byte(lzmaNoMarkerExpect))
+	for _, dd := range []bool{false, true} {
+		entry := makeEntry("lzma.txt", LZMA, lzmaNoMarkerData, sum, uint32(len(lzmaNoMarkerExpect)), dd)
+
+		var archive bytes.Buffer
+		archive.Write(entry)
+		archive.Write(sigCentralDirectoryHeader)
+		testReadEntry(t, archive.Bytes(), lzmaNoMarkerExpect)
+	}
+}
@@ -3,11 +3,13 @@ package uncozip
 import (
 	"bufio"
 	"bytes"
+	"compress/bzip2"
 	"compress/flate"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"math"
 	"path/filepath"
 	"strings"
@@ -16,24 +18,91 @@ import (
 
 	"golang.org/x/text/transform"
 
+	"github.com/klauspost/compress/zstd"
 	"github.com/nyaosorg/go-windows-mbcs"
+	"github.com/ulikunitz/xz"
 )
 
 const (
-	Store   = 0
-	Deflate = 8
+	Store     = 0
+	Deflate   = 8
+	Deflate64 = 9
+	Bzip2     = 12
+	LZMA      = 14
+	Zstd      = 93
+	XZ        = 95
 
 	bitEncrypted          = 1 << 0
 	bitDataDescriptorUsed = 1 << 3
 	bitEncodedUTF8        = 1 << 11
 
-	sigSize            = 4
-	dataDescriptorSize = 4 * 3
+	sigSize              = 4
+	dataDescriptorSize   = 4 * 3
+	dataDescriptor64Size = 4 + 8*2
 )
 
+func newBzip2Reader(r io.Reader) io.ReadCloser {
+	return io.NopCloser(bzip2.NewReader(r))
+}
+
+// errorReader is returned by decompressors which fail to start.
+type errorReader struct {
+	err error
+}
+
+func (e *errorReader) Read([]byte) (int, error) {
+	return 0, e.err
+}
+
+func (e *errorReader) Close() error {
+	return nil
+}
+
+type zstdReader struct {
+	*zstd.Decoder
+}
+
+func (z zstdReader) Close() error {
+	z.Decoder.Close()
+	return nil
+}
+
+func newZstdReader(r io.Reader) io.ReadCloser {
+	d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return &errorReader{err: err}
+	}
+	return zstdReader{Decoder: d}
+}
+
+func newXZReader(r io.Reader) io.ReadCloser {
+	x, err := xz.NewReader(r)
+	if err != nil {
+		return &errorReader{err: err}
+	}
+	return io.NopCloser(x)
+}
+
 var decompressors = map[uint16]func(io.Reader) io.ReadCloser{
-	Store:   io.NopCloser,
-	Deflate: flate.NewReader,
+	Store:     io.NopCloser,
+	Deflate:   flate.NewReader,
+	Deflate64: newDeflate64Reader,
+	Bzip2:     newBzip2Reader,
+	Zstd:      newZstdReader,
+	XZ:        newXZReader,
+}
+
+// entryDecompressors are for the methods which need the general purpose
+// flags and the uncompressed size of the entry.
+// The size is -1 when it is written only in the data descriptor.
+var entryDecompressors = map[uint16]func(r io.Reader, bits uint16, size int64) io.ReadCloser{
+	Shrink:  newShrinkReader,
+	Reduce1: newReduceReader(Reduce1),
+	Reduce2: newReduceReader(Reduce2),
+	Reduce3: newReduceReader(Reduce3),
+	Reduce4: newReduceReader(Reduce4),
+	Implode: newImplodeReader,
+	LZMA:    newLZMAReader,
 }
 
 type _LocalFileHeader struct {
@@ -93,6 +162,21 @@ type _DataDescriptor struct {
 	UncompressedSize uint32
 }
 
+// _DataDescriptor64 is the data descriptor for ZIP64.
+// The sizes of _DataDescriptor are also stored as this type.
+type _DataDescriptor64 struct {
+	CRC32            uint32
+	CompressedSize   uint64
+	UncompressedSize uint64
+}
+
+func dataDescriptorSizeOf(zip64 bool) int {
+	if zip64 {
+		return dataDescriptor64Size
+	}
+	return dataDescriptorSize
+}
+
 var (
 	sigLocalFileHeader        = []byte{'P', 'K', 3, 4}
 	sigCentralDirectoryHeader = []byte{'P', 'K', 1, 2}
@@ -102,26 +186,50 @@ var (
 
 var (
 	ErrLocalFileHeaderSignatureNotFound = errors.New("signature not found")
+	ErrChecksum                         = errors.New("CRC32 mismatch")
+	ErrCentralDirectoryNotFound         = errors.New("central directory not found")
 )
 
-func checkDataDescriptor(buffer []byte) *_DataDescriptor {
-	var desc _DataDescriptor
-	start := len(buffer) - sigSize - dataDescriptorSize
-	if start < 0 {
+// parseDataDescriptor decodes the data descriptor (without the signature) at the head of b.
+func parseDataDescriptor(b []byte, zip64 bool) *_DataDescriptor64 {
+	if len(b) < dataDescriptorSizeOf(zip64) {
 		return nil
 	}
-	reader := bytes.NewReader(buffer[start:])
-	if err := binary.Read(reader, binary.LittleEndian, &desc); err != nil {
+	if zip64 {
+		return &_DataDescriptor64{
+			CRC32:            binary.LittleEndian.Uint32(b),
+			CompressedSize:   binary.LittleEndian.Uint64(b[4:]),
+			UncompressedSize: binary.LittleEndian.Uint64(b[12:]),
+		}
+	}
+	return &_DataDescriptor64{
+		CRC32:            binary.LittleEndian.Uint32(b),
+		CompressedSize:   uint64(binary.LittleEndian.Uint32(b[4:])),
+		UncompressedSize: uint64(binary.LittleEndian.Uint32(b[8:])),
+	}
+}
+
+// checkDataDescriptor reads the data descriptor just before the last signature in buffer.
+func checkDataDescriptor(buffer []byte, zip64 bool) *_DataDescriptor64 {
+	start := len(buffer) - sigSize - dataDescriptorSizeOf(zip64)
+	if start < 0 {
 		return nil
 	}
-	return &desc
+	return parseDataDescriptor(buffer[start:], zip64)
 }
 
-func seekToSignature(r io.ByteReader, w io.Writer, debug func(...any)) (bool, *_DataDescriptor, error) {
+// seekToSignature copies the entry data to w until the data descriptor and
+// the next signature. Both 32-bit and 64-bit forms of the data descriptor are
+// recognised. When zip64 is true, the 64-bit form is tried first.
+func seekToSignature(r io.ByteReader, w io.Writer, zip64 bool, debug func(...any)) (bool, *_DataDescriptor64, error) {
 	const (
 		max = 100
-		min = sigSize + dataDescriptorSize + sigSize
+		min = sigSize + dataDescriptor64Size + sigSize
 	)
+	forms := []bool{false, true}
+	if zip64 {
+		forms = []bool{true, false}
+	}
 
 	buffer := make([]byte, 0, max)
 	count := 0
@@ -134,44 +242,41 @@ func seekToSignature(r io.ByteReader, w io.Writer, debug func(...any)) (bool, *_
 		buffer = append(buffer, ch)
 		count++
 
+		var hasNextEntry bool
 		switch ch {
 		case sigLocalFileHeader[sigSize-1]:
-			if bytes.HasSuffix(buffer, sigLocalFileHeader) {
-				dd := checkDataDescriptor(buffer)
-				if dd != nil {
-					size := int(dd.CompressedSize)
-					if size == count-sigSize-dataDescriptorSize {
-						w.Write(buffer[:len(buffer)-sigSize-dataDescriptorSize])
-						debug("Found DetaDescripture without signature")
-						return true, dd, nil
-					}
-					if size == count-sigSize-dataDescriptorSize-sigSize &&
-						bytes.HasSuffix(buffer[:len(buffer)-sigSize-dataDescriptorSize], sigDataDescriptor) {
-						w.Write(buffer[:len(buffer)-sigSize-dataDescriptorSize-sigSize])
-						debug("Found DataDescriptor with signature")
-						return true, dd, nil
-					}
-				}
+			if !bytes.HasSuffix(buffer, sigLocalFileHeader) {
+				goto next
 			}
+			hasNextEntry = true
 		case sigCentralDirectoryHeader[sigSize-1]:
-			if bytes.HasSuffix(buffer, sigCentralDirectoryHeader) {
-				dd := checkDataDescriptor(buffer)
-				if dd != nil {
-					size := int(dd.CompressedSize)
-					if size == count-sigSize-dataDescriptorSize {
-						w.Write(buffer[:len(buffer)-sigSize-dataDescriptorSize])
-						debug("Found DetaDescripture without signature")
-						return false, dd, nil
-					}
-					if size == count-sigSize-dataDescriptorSize-sigSize &&
-						bytes.HasSuffix(buffer[:len(buffer)-sigSize-dataDescriptorSize], sigDataDescriptor) {
-						w.Write(buffer[:len(buffer)-sigSize-dataDescriptorSize-sigSize])
-						debug("Found DetaDescripture with signature")
-						return false, dd, nil
-					}
-				}
+			if !bytes.HasSuffix(buffer, sigCentralDirectoryHeader) {
+				goto next
+			}
+			hasNextEntry = false
+		default:
+			goto next
+		}
+		for _, zip64 := range forms {
+			dd := checkDataDescriptor(buffer, zip64)
+			if dd == nil {
+				continue
+			}
+			ddSize := dataDescriptorSizeOf(zip64)
+			size := int64(dd.CompressedSize)
+			if size == int64(count-sigSize-ddSize) {
+				w.Write(buffer[:len(buffer)-sigSize-ddSize])
+				debug("Found DataDescriptor without signature (ZIP64:", zip64, ")")
+				return hasNextEntry, dd, nil
+			}
+			if size == int64(count-sigSize-ddSize-sigSize) &&
+				bytes.HasSuffix(buffer[:len(buffer)-sigSize-ddSize], sigDataDescriptor) {
+				w.Write(buffer[:len(buffer)-sigSize-ddSize-sigSize])
+				debug("Found DataDescriptor with signature (ZIP64:", zip64, ")")
+				return hasNextEntry, dd, nil
 			}
 		}
+	next:
 		if len(buffer) >= max {
 			w.Write(buffer[:len(buffer)-min])
 			copy(buffer[:min], buffer[len(buffer)-min:])
@@ -201,7 +306,9 @@ func (p *_PasswordHolder) Ask(name string, retry bool) ([]byte, error) {
 }
 
 type readResult struct {
-	*_DataDescriptor
+	_DataDescriptor64
+	found        bool  // the data descriptor is found
+	dataSize     int64 // the size of the entry data before the data descriptor
 	hasNextEntry bool
 	err          error
 }
@@ -223,11 +330,21 @@ func (u *lazyReadResult) Value() *readResult {
 // CorruptedZip is a reader for a ZIP archive that reads from io.Reader instead of io.ReaderAt
 type CorruptedZip struct {
 	closers                  []func()
+	counter                  *countingReader
 	br                       *bufio.Reader
 	name                     string
+	rawName                  []byte
+	headerOffset             int64
+	dataOffset               int64
+	dataEndOffset            func() int64
+	dataDescriptorOffset     func() int64
 	rawFileData              io.Reader
+	raw                      io.Reader // the entry data before decryption
+	rawMode                  bool      // read only the raw data without decryption and decompression
+	body                     io.Reader
 	err                      error
 	nextSignatureAlreadyRead bool
+	atCentralDirectory       bool // the signature of the central directory header has been read
 
 	LastModificationTime time.Time
 	LastAccessTime       time.Time
@@ -241,11 +358,32 @@ type CorruptedZip struct {
 	bgErr        func() error
 	fnameDecoder func([]byte) (string, error)
 
+	recovery bool
+	onSkip   func(start, end int64)
+
+	decompressors      map[uint16]Decompressor
+	extraFieldHandlers map[uint16]ExtraFieldHandler
+	extraFields        []ExtraField
+
 	header         _LocalFileHeader
+	method         uint16
+	zip64          bool
+	unixMode       *fs.FileMode
+	owner          *_UnixOwner
+	stampSource    int
+	unicodePath    bool  // the name is taken from the Unicode Path extra field
+	dataRead       *bool // the body or the raw data of the entry is read all
+	aes            *_WinZipAES
 	passwordHolder _PasswordHolder
 
 	// Debug outputs debug-log. When the field is not set, debug-log is dropped.
 	Debug func(...any)
+
+	// DelimitByDecompressor makes the end of the entry data with the data descriptor
+	// found by the decompressor instead of searching for the next signature.
+	// It is effective for Deflate, Deflate64 and LZMA with the end-of-stream marker
+	// which are not encrypted. The other entries are still delimited by the signature.
+	DelimitByDecompressor bool
 }
 
 // originalSize returns the current file's uncompressed size written in "local file header" or "data descriptor".
@@ -269,9 +407,28 @@ func (cz *CorruptedZip) CRC32() uint32 {
 	return cz.crc32()
 }
 
-// Method returns the mothod to compress the current entry data
+// Method returns the mothod to compress the current entry data.
+// For an entry encrypted with WinZip AES, it returns the method written in the extra field.
 func (cz *CorruptedZip) Method() uint16 {
-	return cz.header.Method
+	return cz.method
+}
+
+// HasCRC32 returns false when the current entry does not have CRC32 (WinZip AE-2).
+// The integrity of such an entry is checked with the authentication code while reading Body.
+func (cz *CorruptedZip) HasCRC32() bool {
+	return cz.aes == nil || cz.aes.Version != 2
+}
+
+// IsEncrypted returns true when the current entry is encrypted.
+func (cz *CorruptedZip) IsEncrypted() bool {
+	return (cz.header.Bits & bitEncrypted) != 0
+}
+
+// HasDataDescriptor returns true when the sizes and CRC32 of the current entry
+// are written in the data descriptor after the entry data.
+// Then OriginalSize, CompressedSize and CRC32 are known after Body is read all.
+func (cz *CorruptedZip) HasDataDescriptor() bool {
+	return (cz.header.Bits & bitDataDescriptorUsed) != 0
 }
 
 // RegisterPasswordHandler sets a callback function to query password to an user.
@@ -289,6 +446,36 @@ func (cz *CorruptedZip) Name() string {
 	return cz.name
 }
 
+// RawName returns the filename of the most recent file as it is stored in the local file header.
+func (cz *CorruptedZip) RawName() []byte {
+	return cz.rawName
+}
+
+// LocalHeaderOffset returns the offset of the local file header of the most recent file
+// in the input stream.
+func (cz *CorruptedZip) LocalHeaderOffset() int64 {
+	return cz.headerOffset
+}
+
+// DataOffset returns the offset of the data of the most recent file in the input stream.
+// For an encrypted file, the data starts with the encryption header.
+func (cz *CorruptedZip) DataOffset() int64 {
+	return cz.dataOffset
+}
+
+// DataEndOffset returns the offset just after the data of the most recent file in the input stream.
+// When an "data descriptor" exists, it waits until file data stream is read all.
+func (cz *CorruptedZip) DataEndOffset() int64 {
+	return cz.dataEndOffset()
+}
+
+// DataDescriptorOffset returns the offset of the data descriptor (including its signature if exists)
+// of the most recent file in the input stream. It returns -1 when the data descriptor is not found.
+// It waits until file data stream is read all.
+func (cz *CorruptedZip) DataDescriptorOffset() int64 {
+	return cz.dataDescriptorOffset()
+}
+
 // Err returns the error encountered by the CorruptedZip.
 func (cz *CorruptedZip) Err() error {
 	if cz.err != nil {
@@ -297,18 +484,77 @@ func (cz *CorruptedZip) Err() error {
 	return cz.bgErr()
 }
 
+// Skip reads the rest of the data of the most recent file without
+// decompression and decryption. The values in the data descriptor are
+// available after Skip without the password.
+func (cz *CorruptedZip) Skip() error {
+	if cz.raw == nil {
+		return nil
+	}
+	if _, err := io.Copy(io.Discard, cz.raw); err != nil {
+		return err
+	}
+	*cz.dataRead = true
+	return cz.bgErr()
+}
+
 // Body returns the reader of the most recent file by a call to Scan.
+// When the compression method is not supported, the reader returns the error
+// so that the other entries can be read.
 func (cz *CorruptedZip) Body() io.Reader {
 	if cz.rawFileData == nil {
 		return bytes.NewReader([]byte{})
 	}
-	f, ok := decompressors[cz.header.Method]
-	if !ok {
-		return bytes.NewReader([]byte{})
+	if cz.body != nil {
+		return &markingReader{r: cz.body, eof: cz.dataRead}
+	}
+	f := cz.decompressor()
+	if f == nil {
+		return &errorReader{err: fmt.Errorf("compression method(%d) is not supported", cz.method)}
 	}
 	r := f(cz.rawFileData)
 	cz.closers = append(cz.closers, func() { r.Close() })
-	return r
+	return &markingReader{r: r, eof: cz.dataRead}
+}
+
+// markingReader sets true to *eof when r reaches io.EOF.
+type markingReader struct {
+	r   io.Reader
+	eof *bool
+}
+
+func (m *markingReader) Read(p []byte) (int, error) {
+	n, err := m.r.Read(p)
+	if err == io.EOF {
+		*m.eof = true
+	}
+	return n, err
+}
+
+func (cz *CorruptedZip) decompressor() func(io.Reader) io.ReadCloser {
+	if f, ok := cz.decompressors[cz.method]; ok {
+		return f
+	}
+	decompressorsMu.RLock()
+	f, ok := registeredDecompressors[cz.method]
+	decompressorsMu.RUnlock()
+	if ok {
+		return f
+	}
+	if f, ok := decompressors[cz.method]; ok {
+		return f
+	}
+	if f, ok := entryDecompressors[cz.method]; ok {
+		bits := cz.header.Bits
+		return func(r io.Reader) io.ReadCloser {
+			size := int64(-1)
+			if (bits & bitDataDescriptorUsed) == 0 {
+				size = int64(cz.OriginalSize())
+			}
+			return f(r, bits, size)
+		}
+	}
+	return nil
 }
 
 // IsDir returns true when the current file is a directory.
@@ -326,8 +572,10 @@ func defaultFNameDecoder(b []byte) (string, error) {
 
 // New returns a CorruptedZip instance that reads a ZIP archive.
 func New(r io.Reader) *CorruptedZip {
+	counter := &countingReader{r: r}
 	return &CorruptedZip{
-		br:           bufio.NewReader(r),
+		counter:      counter,
+		br:           bufio.NewReaderSize(counter, 1<<16),
 		Debug:        func(...any) {},
 		bgErr:        func() error { return nil },
 		hasNextEntry: func() bool { return true },
@@ -336,11 +584,11 @@ func New(r io.Reader) *CorruptedZip {
 	}
 }
 
-func readFilenameField(r io.Reader, n uint16, utf8 bool, decoder func([]byte) (string, error)) (string, error) {
+func readFilenameField(r io.Reader, n uint16, utf8 bool, decoder func([]byte) (string, error)) (string, []byte, error) {
 	name := make([]byte, n)
 
 	if _, err := io.ReadFull(r, name[:]); err != nil {
-		return "", err
+		return "", nil, err
 	}
 	var fname string
 	if utf8 {
@@ -349,10 +597,10 @@ func readFilenameField(r io.Reader, n uint16, utf8 bool, decoder func([]byte) (s
 		var err error
 		fname, err = decoder(name)
 		if err != nil {
-			return fname, err
+			return fname, name, err
 		}
 	}
-	return strings.TrimLeft(fname, "/"), nil
+	return strings.TrimLeft(fname, "/"), name, nil
 }
 
 func readZIP64(r io.Reader, cz *CorruptedZip) error {
@@ -371,6 +619,7 @@ func readZIP64(r io.Reader, cz *CorruptedZip) error {
 	}
 	cz.compressedSize = func() uint64 { return compSize }
 	cz.Debug("  ExtendField: ZIP64.CompressSize:", compSize)
+	cz.zip64 = true
 	return nil
 }
 
@@ -384,36 +633,117 @@ func readAsSecondsSince1970(r io.Reader) (tm time.Time, err error) {
 	return
 }
 
+// The sources of the timestamps. The latter has the higher precedence
+// regardless of the order of the extra fields.
+const (
+	stampDOS      = iota // the local file header (2 seconds precision)
+	stampExtended        // the extended timestamp 0x5455 (1 second precision)
+	stampNTFS            // the NTFS extra field 0x000A (100 ns precision)
+)
+
 func readTimeStamp(r io.Reader, cz *CorruptedZip) error {
 	var bitflag [1]byte
 	err := binary.Read(r, binary.LittleEndian, &bitflag)
 	if err != nil {
 		return fmt.Errorf("extended fileStamp bit field can not read: %w", err)
 	}
+	if cz.stampSource > stampExtended {
+		cz.Debug("  Ignore: Extended Timestamp (NTFS timestamps found)")
+		return nil
+	}
+	cz.stampSource = stampExtended
+	// The flags may tell the times which are not stored actually
+	// (e.g. the central directory has only the modification time).
+	// The missing times are ignored.
 	if (bitflag[0] & 1) != 0 {
-		cz.LastModificationTime, err = readAsSecondsSince1970(r)
+		tm, err := readAsSecondsSince1970(r)
+		if err == io.EOF {
+			return nil
+		}
 		if err != nil {
 			return fmt.Errorf("last modified dateTime: %w", err)
 		}
+		cz.LastModificationTime = tm
 		cz.Debug("  Last Modification Time:", cz.LastModificationTime)
 	}
 	if (bitflag[0] & 2) != 0 {
-		cz.LastAccessTime, err = readAsSecondsSince1970(r)
+		tm, err := readAsSecondsSince1970(r)
+		if err == io.EOF {
+			return nil
+		}
 		if err != nil {
 			return fmt.Errorf("last access dateTime: %w", err)
 		}
+		cz.LastAccessTime = tm
 		cz.Debug("  Last Access Time:", cz.LastAccessTime)
 	}
 	if (bitflag[0] & 4) != 0 {
-		cz.CreationTime, err = readAsSecondsSince1970(r)
+		tm, err := readAsSecondsSince1970(r)
+		if err == io.EOF {
+			return nil
+		}
 		if err != nil {
 			return fmt.Errorf("creation time: %w", err)
 		}
+		cz.CreationTime = tm
 		cz.Debug("  Create Time:", cz.CreationTime)
 	}
 	return nil
 }
 
+// filetimeToTime converts FILETIME of Windows, the count of 100 ns since
+// 1601-01-01 UTC, to time.Time.
+func filetimeToTime(ft uint64) time.Time {
+	const secondsFrom1601To1970 = 11644473600
+	return time.Unix(int64(ft/1e7)-secondsFrom1601To1970, int64(ft%1e7)*100)
+}
+
+func readNTFS(r io.Reader, cz *CorruptedZip) error {
+	var reserved uint32
+	if err := binary.Read(r, binary.LittleEndian, &reserved); err != nil {
+		return fmt.Errorf("NTFS extra field: %w", err)
+	}
+	for {
+		var attr struct {
+			Tag  uint16
+			Size uint16
+		}
+		if err := binary.Read(r, binary.LittleEndian, &attr); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("NTFS extra field: %w", err)
+		}
+		if attr.Tag != 1 || attr.Size < 24 {
+			cz.Debug("  Ignore: NTFS attribute tag", attr.Tag)
+			if _, err := io.CopyN(io.Discard, r, int64(attr.Size)); err != nil {
+				return fmt.Errorf("NTFS extra field: %w", err)
+			}
+			continue
+		}
+		var stamp [3]uint64
+		if err := binary.Read(r, binary.LittleEndian, &stamp); err != nil {
+			return fmt.Errorf("NTFS timestamps: %w", err)
+		}
+		if _, err := io.CopyN(io.Discard, r, int64(attr.Size)-24); err != nil {
+			return fmt.Errorf("NTFS extra field: %w", err)
+		}
+		cz.stampSource = stampNTFS
+		if stamp[0] != 0 {
+			cz.LastModificationTime = filetimeToTime(stamp[0])
+			cz.Debug("  Last Modification Time(NTFS):", cz.LastModificationTime)
+		}
+		if stamp[1] != 0 {
+			cz.LastAccessTime = filetimeToTime(stamp[1])
+			cz.Debug("  Last Access Time(NTFS):", cz.LastAccessTime)
+		}
+		if stamp[2] != 0 {
+			cz.CreationTime = filetimeToTime(stamp[2])
+			cz.Debug("  Create Time(NTFS):", cz.CreationTime)
+		}
+	}
+}
+
 func readWinACL(r io.Reader, cz *CorruptedZip) error {
 	cz.Debug("  Ignore: Windows NT security descriptor (binary ACL)")
 	var data struct {
@@ -436,77 +766,99 @@ func readWinACL(r io.Reader, cz *CorruptedZip) error {
 }
 
 func readNewUnixExtraField(r io.Reader, cz *CorruptedZip) error {
-	cz.Debug("  Ignore: New Unix Extra Field")
-	var versionAndUidSize [2]byte
-	if _, err := io.ReadFull(r, versionAndUidSize[:]); err != nil {
+	cz.Debug("  New Unix Extra Field")
+	var version [1]byte
+	if _, err := io.ReadFull(r, version[:]); err != nil {
 		return err
 	}
-	cz.Debug("  Version:", versionAndUidSize[0])
+	cz.Debug("  Version:", version[0])
 
-	uid := make([]byte, versionAndUidSize[1])
-	if _, err := io.ReadFull(r, uid[:]); err != nil {
+	uid, err := readUnixID(r)
+	if err != nil {
 		return err
 	}
 	cz.Debug("  UID:", uid)
 
-	var gidSize [1]byte
-	if _, err := io.ReadFull(r, gidSize[:]); err != nil {
+	gid, err := readUnixID(r)
+	if err != nil {
 		return err
 	}
+	cz.Debug("  GID:", gid)
+	cz.owner = &_UnixOwner{UID: uid, GID: gid}
+	return nil
+}
 
-	gid := make([]byte, gidSize[0])
-	if _, err := io.ReadFull(r, gid[:]); err != nil {
-		return err
+// readUnixID reads the size and the little-endian value of UID or GID.
+func readUnixID(r io.Reader) (int, error) {
+	var size [1]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return 0, err
 	}
-	cz.Debug("  GID:", uid)
-	return nil
+	id := make([]byte, size[0])
+	if _, err := io.ReadFull(r, id); err != nil {
+		return 0, err
+	}
+	var value uint64
+	for i := len(id) - 1; i >= 0; i-- {
+		value = value<<8 | uint64(id[i])
+	}
+	return int(value), nil
 }
 
 const (
 	idZIP64   = 0x0001
 	idWinACL  = 0x4453
 	idStamp   = 0x5455
+	idNTFS    = 0x000a
 	idNewUnix = 0x7875
 )
 
 var extendFieldFunc = map[uint16]func(r io.Reader, cz *CorruptedZip) error{
-	idZIP64:   readZIP64,
-	idStamp:   readTimeStamp,
-	idWinACL:  readWinACL,
-	idNewUnix: readNewUnixExtraField,
+	idZIP64:       readZIP64,
+	idStamp:       readTimeStamp,
+	idNTFS:        readNTFS,
+	idWinACL:      readWinACL,
+	idNewUnix:     readNewUnixExtraField,
+	idWinZipAES:   readWinZipAES,
+	idASiUnix:     readASiUnixExtraField,
+	idUnicodePath: readUnicodePath,
 }
 
 func readExtendField(r io.Reader, n uint16, cz *CorruptedZip) (err error) {
 	cz.Debug("ExtendFieldSize:", n)
+	cz.extraFields = nil
 	if n <= 0 {
 		return
 	}
-	lr := &io.LimitedReader{R: r, N: int64(n)}
-	for lr.N >= 4 {
-		var header struct {
-			ID   uint16
-			Size uint16
-		}
-		if e := binary.Read(lr, binary.LittleEndian, &header); e != nil {
-			return fmt.Errorf("ExtendField Error: %w", e)
+	buffer := make([]byte, n)
+	if _, err := io.ReadFull(r, buffer); err != nil {
+		return fmt.Errorf("ExtendField Error: %w", err)
+	}
+	for len(buffer) >= 4 {
+		id := binary.LittleEndian.Uint16(buffer)
+		size := int(binary.LittleEndian.Uint16(buffer[2:]))
+		buffer = buffer[4:]
+		cz.Debug("ExtendField: ID:", id, "Size:", size)
+		if size > len(buffer) {
+			size = len(buffer)
 		}
-		cz.Debug("ExtendField: ID:", header.ID, "Size:", header.Size)
+		data := buffer[:size:size]
+		buffer = buffer[size:]
+		cz.extraFields = append(cz.extraFields, ExtraField{ID: id, Data: data})
 
-		llr := &io.LimitedReader{R: lr, N: int64(header.Size)}
-		if f, ok := extendFieldFunc[header.ID]; ok {
-			if err := f(llr, cz); err != nil {
+		if f, ok := extendFieldFunc[id]; ok {
+			if err := f(bytes.NewReader(data), cz); err != nil {
 				return err
 			}
 		} else {
 			cz.Debug("  Unknown extended field")
 		}
-		if llr.N > 0 {
-			io.Copy(io.Discard, llr)
+		if f := cz.extraFieldHandler(id); f != nil {
+			if err := f(cz, data); err != nil {
+				return err
+			}
 		}
 	}
-	if lr.N > 0 {
-		io.Copy(io.Discard, lr)
-	}
 	return nil
 }
 
@@ -524,16 +876,30 @@ func (cz *CorruptedZip) scan() (err error) {
 		return err
 	}
 	if !cz.hasNextEntry() {
+		cz.atCentralDirectory = true
 		return io.EOF
 	}
 	cz.rawFileData = nil
+	cz.raw = nil
+	cz.body = nil
 
 	if !cz.nextSignatureAlreadyRead {
+		if cz.recovery {
+			sig, err := cz.br.Peek(sigSize)
+			if len(sig) > 0 && !bytes.Equal(sig, sigLocalFileHeader) && !bytes.Equal(sig, sigCentralDirectoryHeader) {
+				if err := cz.resync(); err != nil {
+					return err
+				}
+			} else if err != nil {
+				return err
+			}
+		}
 		var signature [4]byte
 		if _, err := io.ReadFull(cz.br, signature[:]); err != nil {
 			return err
 		}
 		if bytes.Equal(signature[:], sigCentralDirectoryHeader) {
+			cz.atCentralDirectory = true
 			return io.EOF
 		}
 		if !bytes.Equal(signature[:], sigLocalFileHeader) {
@@ -541,6 +907,7 @@ func (cz *CorruptedZip) scan() (err error) {
 		}
 	}
 
+	cz.headerOffset = cz.offset() - sigSize
 	if err := binary.Read(cz.br, binary.LittleEndian, &cz.header); err != nil {
 		return err
 	}
@@ -552,29 +919,60 @@ func (cz *CorruptedZip) scan() (err error) {
 	cz.CreationTime = cz.header.stamp()
 	cz.LastModificationTime = cz.header.stamp()
 	cz.LastAccessTime = cz.header.stamp()
+	cz.stampSource = stampDOS
+	cz.unicodePath = false
+	cz.dataRead = new(bool)
 	cz.Debug("LocalFileHeader")
 	cz.Debug("  ModificatedDate/Time(DOS) :", cz.LastModificationTime)
 	cz.Debug("  CompressSize:", cz.header.CompressedSize)
 	cz.Debug("  UncompressedSize:", cz.header.UncompressedSize)
 
-	cz.name, err = readFilenameField(cz.br, cz.header.FilenameLength, (cz.header.Bits&bitEncodedUTF8) != 0, cz.fnameDecoder)
-	if err != nil {
+	cz.name, cz.rawName, err = readFilenameField(cz.br, cz.header.FilenameLength, (cz.header.Bits&bitEncodedUTF8) != 0, cz.fnameDecoder)
+	if err != nil && cz.rawName == nil {
 		return err
 	}
+	// The error to decode the name is ignored when the Unicode Path extra
+	// field has the name.
+	nameErr := err
 	cz.Debug("  Name:", cz.name)
 
+	cz.aes = nil
+	cz.zip64 = false
+	cz.unixMode = nil
+	cz.owner = nil
 	if err := readExtendField(cz.br, cz.header.ExtendFieldSize, cz); err != nil {
 		return err
 	}
+	if nameErr != nil && !cz.unicodePath {
+		return nameErr
+	}
+	cz.dataOffset = cz.offset()
+	dataOffset := cz.dataOffset
+	cz.dataEndOffset = func() int64 { return dataOffset + int64(cz.CompressedSize()) }
+	cz.dataDescriptorOffset = func() int64 { return -1 }
+	cz.Debug("  DataOffset:", dataOffset)
+
+	cz.method = cz.header.Method
+	if cz.method == WinZipAES {
+		if cz.aes == nil {
+			return errors.New("WinZip AES extra field not found")
+		}
+		cz.method = cz.aes.Method
+	}
 
 	isDir := len(cz.name) > 0 && cz.name[len(cz.name)-1] == '/'
 	if isDir {
 		if (cz.header.Bits & bitDataDescriptorUsed) != 0 {
-			hasNextEntry, _, err := seekToSignature(cz.br, io.Discard, cz.Debug)
+			w := &countingWriter{w: io.Discard}
+			hasNextEntry, dataDescriptor, err := seekToSignature(cz.br, w, cz.zip64, cz.Debug)
 			if err != nil {
 				return err
 			}
 			cz.hasNextEntry = func() bool { return hasNextEntry }
+			cz.dataEndOffset = func() int64 { return dataOffset + w.n }
+			if dataDescriptor != nil {
+				cz.dataDescriptorOffset = cz.dataEndOffset
+			}
 			cz.nextSignatureAlreadyRead = true
 		} else {
 			size := cz.CompressedSize()
@@ -591,15 +989,18 @@ func (cz *CorruptedZip) scan() (err error) {
 
 	if (cz.header.Bits & bitDataDescriptorUsed) != 0 {
 		cz.Debug("LocalFileHeader.Bits: bitDataDescriptorUsed is set")
+		if cz.DelimitByDecompressor && !cz.rawMode && cz.selfDelimiting() {
+			return cz.delimitByDecompressor()
+		}
 
 		c := make(chan readResult)
 
 		ch := &lazyReadResult{channel: c}
 		cz.originalSize = func() uint64 {
-			return uint64(ch.Value().UncompressedSize)
+			return ch.Value().UncompressedSize
 		}
 		cz.compressedSize = func() uint64 {
-			return uint64(ch.Value().CompressedSize)
+			return ch.Value().CompressedSize
 		}
 		cz.crc32 = func() uint32 {
 			return ch.Value().CRC32
@@ -610,6 +1011,15 @@ func (cz *CorruptedZip) scan() (err error) {
 		cz.hasNextEntry = func() bool {
 			return ch.Value().hasNextEntry
 		}
+		cz.dataEndOffset = func() int64 {
+			return dataOffset + ch.Value().dataSize
+		}
+		cz.dataDescriptorOffset = func() int64 {
+			if !ch.Value().found {
+				return -1
+			}
+			return dataOffset + ch.Value().dataSize
+		}
 
 		pipeR, pipeW := io.Pipe()
 		cz.closers = append(cz.closers, func() { pipeR.Close() })
@@ -617,30 +1027,43 @@ func (cz *CorruptedZip) scan() (err error) {
 		cz.rawFileData = pipeR
 
 		go func() {
-			hasNextEntry, dataDescriptor, err := seekToSignature(cz.br, pipeW, cz.Debug)
+			w := &countingWriter{w: pipeW}
+			hasNextEntry, dataDescriptor, err := seekToSignature(cz.br, w, cz.zip64, cz.Debug)
 			pipeW.Close()
-			c <- readResult{
-				_DataDescriptor: dataDescriptor,
-				hasNextEntry:    hasNextEntry,
-				err:             err,
+			result := readResult{
+				dataSize:     w.n,
+				hasNextEntry: hasNextEntry,
+				err:          err,
 			}
+			if dataDescriptor != nil {
+				result._DataDescriptor64 = *dataDescriptor
+				result.found = true
+			}
+			c <- result
 		}()
 	} else {
 		cz.Debug("LocalFileHeader.Bits: bitDataDescriptorUsed is not set")
 		cz.rawFileData = &io.LimitedReader{R: cz.br, N: int64(cz.CompressedSize())}
 		cz.nextSignatureAlreadyRead = false
 	}
+	// Skip the rest of the entry data without decryption when it is not read all.
+	raw := cz.rawFileData
+	cz.closers = append(cz.closers, func() { io.Copy(io.Discard, raw) })
+	cz.raw = raw
+	if cz.rawMode {
+		return nil
+	}
 	if (cz.header.Bits & bitEncrypted) != 0 {
 		if !cz.passwordHolder.Ready() {
 			return &ErrPassword{name: cz.name}
 		}
-		// Use cz.header.ModifiedTime instead of CRC32.
-		// The reason is unknown.
-		cz.rawFileData = transform.NewReader(cz.rawFileData, newDecrypter(cz.name, &cz.passwordHolder, cz.header.ModifiedTime))
-	}
-	cz.closers = append(cz.closers, func() { io.Copy(io.Discard, cz.rawFileData) })
-	if _, ok := decompressors[cz.header.Method]; !ok {
-		return fmt.Errorf("compression method(%d) is not supported", cz.header.Method)
+		if cz.aes != nil {
+			cz.rawFileData = newAESDecrypter(cz.rawFileData, cz.name, cz.aes, &cz.passwordHolder)
+		} else {
+			// Use cz.header.ModifiedTime instead of CRC32.
+			// The reason is unknown.
+			cz.rawFileData = transform.NewReader(cz.rawFileData, newDecrypter(cz.name, &cz.passwordHolder, cz.header.ModifiedTime))
+		}
 	}
 	return nil
 }
@@ -3,6 +3,7 @@ package uncozip
 import (
 	"bytes"
 	"encoding/binary"
+	"hash/crc32"
 	"io"
 	"path/filepath"
 	"strings"
@@ -21,7 +22,7 @@ func TestSeekToSignatureForLocalHeader(t *testing.T) {
 	io.WriteString(&source, "PK\x03\x04")
 
 	var output strings.Builder
-	cont, _, err := seekToSignature(&source, &output, noDebug)
+	cont, _, err := seekToSignature(&source, &output, false, noDebug)
 	if err != nil {
 		t.Fatal(err.Error())
 		return
@@ -44,7 +45,7 @@ func TestSeekToSignatureForCentralDirectoryHeader(t *testing.T) {
 	io.WriteString(&source, "PK\x01\x02")
 
 	var output strings.Builder
-	cont, _, err := seekToSignature(&source, &output, noDebug)
+	cont, _, err := seekToSignature(&source, &output, false, noDebug)
 	if err != nil {
 		t.Fatal(err.Error())
 		return
@@ -79,3 +80,360 @@ func TestSanitizePath(t *testing.T) {
 		}
 	}
 }
+
+// makeEntry builds a local file header and its data. When dd is true,
+// the sizes and CRC32 are written in a data descriptor instead of the header.
+func makeEntry(name string, method uint16, data []byte, crc, size uint32, dd bool) []byte {
+	var buffer bytes.Buffer
+	header := _LocalFileHeader{
+		RequiredVersion: 20,
+		Method:          method,
+		FilenameLength:  uint16(len(name)),
+	}
+	if dd {
+		header.Bits |= bitDataDescriptorUsed
+	} else {
+		header.CRC32 = crc
+		header.CompressedSize = uint32(len(data))
+		header.UncompressedSize = size
+	}
+	buffer.Write(sigLocalFileHeader)
+	binary.Write(&buffer, binary.LittleEndian, &header)
+	buffer.WriteString(name)
+	buffer.Write(data)
+	if dd {
+		buffer.Write(sigDataDescriptor)
+		binary.Write(&buffer, binary.LittleEndian, &_DataDescriptor{
+			CRC32:            crc,
+			CompressedSize:   uint32(len(data)),
+			UncompressedSize: size,
+		})
+	}
+	return buffer.Bytes()
+}
+
+func testReadEntry(t *testing.T, archive []byte, expect string) {
+	t.Helper()
+	cz := New(bytes.NewReader(archive))
+	if !cz.Scan() {
+		t.Fatalf("Scan failed: %v", cz.Err())
+	}
+	var output strings.Builder
+	if _, err := io.Copy(&output, cz.Body()); err != nil {
+		t.Fatal(err.Error())
+	}
+	if out := output.String(); out != expect {
+		t.Fatalf("Body: expect '%s' but '%s'", expect, out)
+	}
+	if sum := crc32.ChecksumIEEE([]byte(expect)); sum != cz.CRC32() {
+		t.Fatalf("CRC32: expect %X but %X", cz.CRC32(), sum)
+	}
+	if cz.Scan() {
+		t.Fatal("expect the end of entries, but found more")
+	}
+	if err := cz.Err(); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+var bzip2Data = []byte("\x42\x5a\x68\x39\x31\x41\x59\x26\x53\x59\x09\x39\x76\x5f\x00\x00\x0b\xdd\x80\x00\x10\x60\x04\x10\x00\x00\x40\x12\x24\xc0\x10\x20\x00\x31\x00\xd0\x00\x55\x40\x06\x9a\x58\xb9\x82\x0f\x19\x34\x6c\x92\x09\x12\x49\xf1\x77\x24\x53\x85\x09\x00\x93\x97\x65\xf0")
+
+func TestBzip2(t *testing.T) {
+	expect := strings.Repeat("Hello, bzip2!\n", 4)
+	sum := crc32.ChecksumIEEE([]byte(expect))
+	for _, dd := range []bool{false, true} {
+		var archive bytes.Buffer
+		archive.Write(makeEntry("hello.txt", Bzip2, bzip2Data, sum, uint32(len(expect)), dd))
+		archive.Write(sigCentralDirectoryHeader)
+		testReadEntry(t, archive.Bytes(), expect)
+	}
+}
+
+// zstdData is made by `zstd -19`
+var zstdData = []byte("\x28\xb5\x2f\xfd\x24\x56\x85\x01\x00\xc2\xc2\x09\x0f\xb0\xeb\x9a\x96\x09\x11\x4d\xdb\x81\x0c\x59\x68\xfa\x34\x06\x02\x04\x85\xda\x25\x9e\xe4\x2e\xbc\xbf\x24\x53\x38\xda\x6e\x53\xf7\x57\x92\x26\xcb\xed\x22\x01\x00\xec\x20\x55\x06\xa9\x3a\xac\x6a")
+
+// xzData is made by `xz -9`
+var xzData = []byte("\xfd\x37\x7a\x58\x5a\x00\x00\x04\xe6\xd6\xb4\x46\x04\xc0\x48\x78\x21\x01\x1c\x00\x00\x00\x00\x00\x00\x00\x00\x00\x0b\xe3\x69\x51\xe0\x00\x77\x00\x40\x5d\x00\x2c\x16\x80\x04\x52\xa8\x0c\x79\x3d\xb3\xd0\x27\xc5\x3f\x8f\x8b\x26\x85\x59\xe7\xdf\x9d\xf1\xdb\x42\x53\x22\x0b\xa8\x6b\x3c\xd8\x44\x23\x01\x0a\x59\xf0\x59\xf5\x92\x0e\xd6\x8e\xba\x34\x25\xaa\x5b\xad\xb0\x1c\x80\x7b\x3e\xc5\xbb\x0e\x9a\xc2\xca\x7d\x40\x00\x00\x63\x72\xbb\x3c\x23\xc5\x04\x7e\x00\x01\x64\x78\x86\xe2\xc9\x7f\x1f\xb6\xf3\x7d\x01\x00\x00\x00\x00\x04\x59\x5a")
+
+func TestZstdAndXZ(t *testing.T) {
+	tests := []struct {
+		method uint16
+		data   []byte
+		expect string
+	}{
+		{Zstd, zstdData, strings.Repeat("Zstandard is a fast compression algorithm.\n", 2)},
+		{XZ, xzData, strings.Repeat("XZ Utils is free general-purpose data compression software.\n", 2)},
+	}
+	for _, tt := range tests {
+		sum := crc32.ChecksumIEEE([]byte(tt.expect))
+		for _, dd := range []bool{false, true} {
+			var archive bytes.Buffer
+			archive.Write(makeEntry("test.txt", tt.method, tt.data, sum, uint32(len(tt.expect)), dd))
+			archive.Write(sigCentralDirectoryHeader)
+			testReadEntry(t, archive.Bytes(), tt.expect)
+		}
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	expect := []string{"first.txt", "second.txt"}
+	var archive bytes.Buffer
+	archive.Write(makeEntry(expect[0], Store, []byte("first"), crc32.ChecksumIEEE([]byte("first")), 5, false))
+	start := int64(archive.Len())
+	archive.Write(make([]byte, 512))
+	archive.WriteString("PK\x03\x04 not a header")
+	end := int64(archive.Len())
+	archive.Write(makeEntry(expect[1], Store, []byte("second"), crc32.ChecksumIEEE([]byte("second")), 6, false))
+	archive.Write(sigCentralDirectoryHeader)
+
+	cz := New(bytes.NewReader(archive.Bytes()))
+	for cz.Scan() {
+	}
+	if cz.Err() != ErrLocalFileHeaderSignatureNotFound {
+		t.Fatalf("expect ErrLocalFileHeaderSignatureNotFound without recovery, but %v", cz.Err())
+	}
+
+	var skipped [][2]int64
+	cz = New(bytes.NewReader(archive.Bytes()))
+	cz.RegisterRecoveryHandler(func(start, end int64) {
+		skipped = append(skipped, [2]int64{start, end})
+	})
+	var names []string
+	for cz.Scan() {
+		names = append(names, cz.Name())
+	}
+	if err := cz.Err(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if strings.Join(names, ",") != strings.Join(expect, ",") {
+		t.Fatalf("expect %v, but %v", expect, names)
+	}
+	if len(skipped) != 1 || skipped[0] != [2]int64{start, end} {
+		t.Fatalf("expect skipped range [%d,%d), but %v", start, end, skipped)
+	}
+}
+
+func TestDelimitByDecompressor(t *testing.T) {
+	// The content has a fake data descriptor and a local file header signature
+	// which the signature search takes for the end of the entry data.
+	var content bytes.Buffer
+	content.WriteString("HOGEHOGE")
+	const storedBlockHeaderSize = 5
+	binary.Write(&content, binary.LittleEndian, &_DataDescriptor{CompressedSize: storedBlockHeaderSize + 8})
+	content.WriteString("PK\x03\x04 is the signature of the local file header")
+	expect := content.String()
+
+	// a deflate stream with one stored block
+	var w bitWriter
+	w.writeBits(1, 1)
+	w.writeBits(0, 2)
+	w.flush()
+	w.writeBits(uint32(content.Len()), 16)
+	w.writeBits(^uint32(content.Len())&0xFFFF, 16)
+	w.Write(content.Bytes())
+
+	var archive bytes.Buffer
+	archive.Write(makeEntry("nested.bin", Deflate, w.Bytes(), crc32.ChecksumIEEE(content.Bytes()), uint32(content.Len()), true))
+	archive.Write(sigCentralDirectoryHeader)
+
+	cz := New(bytes.NewReader(archive.Bytes()))
+	cz.DelimitByDecompressor = true
+	if !cz.Scan() {
+		t.Fatalf("Scan failed: %v", cz.Err())
+	}
+	var output strings.Builder
+	if _, err := io.Copy(&output, cz.Body()); err != nil {
+		t.Fatal(err.Error())
+	}
+	if out := output.String(); out != expect {
+		t.Fatalf("Body: expect '%s' but '%s'", expect, out)
+	}
+	if size := cz.CompressedSize(); size != uint64(w.Len()) {
+		t.Fatalf("CompressedSize: expect %d but %d", w.Len(), size)
+	}
+	if size := cz.DataEndOffset() - cz.DataOffset(); size != int64(w.Len()) {
+		t.Fatalf("DataEndOffset-DataOffset: expect %d but %d", w.Len(), size)
+	}
+	if offset := cz.DataDescriptorOffset(); offset != cz.DataEndOffset() {
+		t.Fatalf("DataDescriptorOffset: expect %d but %d", cz.DataEndOffset(), offset)
+	}
+	if cz.Scan() {
+		t.Fatal("expect the end of entries, but found more")
+	}
+	if err := cz.Err(); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func TestDataDescriptor64(t *testing.T) {
+	const name = "zip64.txt"
+	const content = "HOGEHOGE"
+	crc := crc32.ChecksumIEEE([]byte(content))
+
+	// a deflate stream with one stored block
+	var w bitWriter
+	w.writeBits(1, 1)
+	w.writeBits(0, 2)
+	w.flush()
+	w.writeBits(uint32(len(content)), 16)
+	w.writeBits(^uint32(len(content))&0xFFFF, 16)
+	w.WriteString(content)
+	data := w.Bytes()
+
+	var extra bytes.Buffer
+	binary.Write(&extra, binary.LittleEndian, []uint16{idZIP64, 16})
+	binary.Write(&extra, binary.LittleEndian, []uint64{0, 0})
+
+	var archive bytes.Buffer
+	archive.Write(sigLocalFileHeader)
+	binary.Write(&archive, binary.LittleEndian, &_LocalFileHeader{
+		RequiredVersion:  45,
+		Bits:             bitDataDescriptorUsed,
+		Method:           Deflate,
+		CompressedSize:   0xFFFFFFFF,
+		UncompressedSize: 0xFFFFFFFF,
+		FilenameLength:   uint16(len(name)),
+		ExtendFieldSize:  uint16(extra.Len()),
+	})
+	archive.WriteString(name)
+	archive.Write(extra.Bytes())
+	archive.Write(data)
+	archive.Write(sigDataDescriptor)
+	binary.Write(&archive, binary.LittleEndian, &_DataDescriptor64{
+		CRC32:            crc,
+		CompressedSize:   uint64(len(data)),
+		UncompressedSize: uint64(len(content)),
+	})
+	archive.Write(sigCentralDirectoryHeader)
+
+	for _, delimit := range []bool{false, true} {
+		cz := New(bytes.NewReader(archive.Bytes()))
+		cz.DelimitByDecompressor = delimit
+		if !cz.Scan() {
+			t.Fatalf("Scan failed: %v", cz.Err())
+		}
+		var output strings.Builder
+		if _, err := io.Copy(&output, cz.Body()); err != nil {
+			t.Fatal(err.Error())
+		}
+		if out := output.String(); out != content {
+			t.Fatalf("Body: expect '%s' but '%s'", content, out)
+		}
+		if size := cz.OriginalSize(); size != uint64(len(content)) {
+			t.Fatalf("OriginalSize: expect %d but %d", len(content), size)
+		}
+		if size := cz.CompressedSize(); size != uint64(len(data)) {
+			t.Fatalf("CompressedSize: expect %d but %d", len(data), size)
+		}
+		if cz.CRC32() != crc {
+			t.Fatalf("CRC32: expect %X but %X", crc, cz.CRC32())
+		}
+		if cz.Scan() {
+			t.Fatal("expect the end of entries, but found more")
+		}
+		if err := cz.Err(); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+}
+
+func TestOffsets(t *testing.T) {
+	first := makeEntry("first.txt", Store, []byte("first"), crc32.ChecksumIEEE([]byte("first")), 5, false)
+	second := makeEntry("second.txt", Store, []byte("second"), crc32.ChecksumIEEE([]byte("second")), 6, true)
+	var archive bytes.Buffer
+	archive.Write(first)
+	archive.Write(second)
+	archive.Write(sigCentralDirectoryHeader)
+
+	secondOffset := int64(len(first))
+	secondData := secondOffset + localFileHeaderSize + int64(len("second.txt"))
+	expect := []struct {
+		header, data, end, dd int64
+	}{
+		{0, localFileHeaderSize + int64(len("first.txt")), int64(len(first)), -1},
+		{secondOffset, secondData, secondData + 6, secondData + 6},
+	}
+
+	cz := New(bytes.NewReader(archive.Bytes()))
+	for i := 0; cz.Scan(); i++ {
+		if _, err := io.Copy(io.Discard, cz.Body()); err != nil {
+			t.Fatal(err.Error())
+		}
+		e := expect[i]
+		if v := cz.LocalHeaderOffset(); v != e.header {
+			t.Fatalf("%s: LocalHeaderOffset: expect %d but %d", cz.Name(), e.header, v)
+		}
+		if v := cz.DataOffset(); v != e.data {
+			t.Fatalf("%s: DataOffset: expect %d but %d", cz.Name(), e.data, v)
+		}
+		if v := cz.DataEndOffset(); v != e.end {
+			t.Fatalf("%s: DataEndOffset: expect %d but %d", cz.Name(), e.end, v)
+		}
+		if v := cz.DataDescriptorOffset(); v != e.dd {
+			t.Fatalf("%s: DataDescriptorOffset: expect %d but %d", cz.Name(), e.dd, v)
+		}
+	}
+	if err := cz.Err(); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func TestSkip(t *testing.T) {
+	// The data of the encrypted entry is skipped without the password.
+	data := []byte("0123456789ABCDEF0123456789")
+	var archive bytes.Buffer
+	entry := makeEntry("secret.txt", Store, data, 0x12345678, 14, true)
+	entry[6] |= bitEncrypted
+	archive.Write(entry)
+	archive.Write(sigCentralDirectoryHeader)
+
+	cz := New(bytes.NewReader(archive.Bytes()))
+	cz.RegisterPasswordHandler(func(string) ([]byte, error) {
+		t.Fatal("the password is asked")
+		return nil, nil
+	})
+	if !cz.Scan() {
+		t.Fatalf("Scan failed: %v", cz.Err())
+	}
+	if err := cz.Skip(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if cz.CRC32() != 0x12345678 || cz.CompressedSize() != uint64(len(data)) || cz.OriginalSize() != 14 {
+		t.Fatalf("unexpected %X %d %d", cz.CRC32(), cz.CompressedSize(), cz.OriginalSize())
+	}
+	if cz.Scan() {
+		t.Fatal("expect the end of the entries")
+	}
+	if err := cz.Err(); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func TestUnsupportedMethod(t *testing.T) {
+	// The entry of the unknown method does not stop reading the archive.
+	data := []byte("store")
+	var archive bytes.Buffer
+	archive.Write(makeEntry("unknown.bin", 97, []byte("?????"), 0, 5, true))
+	archive.Write(makeEntry("store.txt", Store, data, crc32.ChecksumIEEE(data), uint32(len(data)), false))
+	archive.Write(sigCentralDirectoryHeader)
+
+	cz := New(bytes.NewReader(archive.Bytes()))
+	if !cz.Scan() {
+		t.Fatalf("Scan failed: %v", cz.Err())
+	}
+	if _, err := io.ReadAll(cz.Body()); err == nil {
+		t.Fatal("expect the error for the unknown method")
+	}
+	if err := cz.Skip(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if cz.Method() != 97 || cz.OriginalSize() != 5 {
+		t.Fatalf("unexpected method %d and size %d", cz.Method(), cz.OriginalSize())
+	}
+	if !cz.Scan() {
+		t.Fatalf("Scan failed: %v", cz.Err())
+	}
+	if cz.Name() != "store.txt" {
+		t.Fatalf("expect store.txt, but %s", cz.Name())
+	}
+}
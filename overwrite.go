@@ -0,0 +1,82 @@
+package uncozip
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// OverwritePolicy is how to treat the file which already exists on extraction.
+type OverwritePolicy int
+
+const (
+	OverwriteAlways  OverwritePolicy = iota // overwrite the existing file
+	OverwriteNever                          // keep the existing file and skip the entry
+	OverwriteIfNewer                        // overwrite only when the entry is newer than the existing file
+	OverwriteRename                         // write the entry into the new name with a suffix
+	OverwritePrompt                         // ask with the Prompt callback
+)
+
+// Overwriter applies an OverwritePolicy to the files to be extracted.
+type Overwriter struct {
+	Policy OverwritePolicy
+
+	// Prompt is called for OverwritePrompt when the file exists. It returns
+	// the policy for the file and whether the policy applies to all the
+	// following files. Returning OverwritePrompt means OverwriteNever.
+	Prompt func(name string) (policy OverwritePolicy, all bool, err error)
+}
+
+// Target returns the filename to extract an entry modified at modTime into
+// the file `name`. It returns "" when the entry should be skipped.
+func (o *Overwriter) Target(name string, modTime time.Time) (string, error) {
+	info, err := os.Lstat(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return name, nil
+		}
+		return "", err
+	}
+	policy := o.Policy
+	if policy == OverwritePrompt {
+		if o.Prompt == nil {
+			return "", fmt.Errorf("%s: already exists", name)
+		}
+		var all bool
+		policy, all, err = o.Prompt(name)
+		if err != nil {
+			return "", err
+		}
+		if all {
+			o.Policy = policy
+		}
+	}
+	switch policy {
+	case OverwriteAlways:
+		return name, nil
+	case OverwriteIfNewer:
+		if modTime.After(info.ModTime()) {
+			return name, nil
+		}
+	case OverwriteRename:
+		return renameWithSuffix(name)
+	}
+	return "", nil
+}
+
+// renameWithSuffix returns the name like "foo_1.txt" which does not exist.
+func renameWithSuffix(name string) (string, error) {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 1; ; i++ {
+		newName := fmt.Sprintf("%s_%d%s", base, i, ext)
+		if _, err := os.Lstat(newName); err != nil {
+			if os.IsNotExist(err) {
+				return newName, nil
+			}
+			return "", err
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package uncozip
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOverwriter(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "foo.txt")
+	if err := os.WriteFile(name, []byte("old"), 0644); err != nil {
+		t.Fatal(err.Error())
+	}
+	stamp := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(name, stamp, stamp); err != nil {
+		t.Fatal(err.Error())
+	}
+	missing := filepath.Join(dir, "missing.txt")
+	renamed := filepath.Join(dir, "foo_1.txt")
+	tests := []struct {
+		policy  OverwritePolicy
+		name    string
+		modTime time.Time
+		expect  string
+	}{
+		{OverwriteNever, missing, stamp, missing},
+		{OverwriteAlways, name, stamp, name},
+		{OverwriteNever, name, stamp, ""},
+		{OverwriteIfNewer, name, stamp.Add(time.Hour), name},
+		{OverwriteIfNewer, name, stamp.Add(-time.Hour), ""},
+		{OverwriteRename, name, stamp, renamed},
+	}
+	for _, tt := range tests {
+		o := &Overwriter{Policy: tt.policy}
+		got, err := o.Target(tt.name, tt.modTime)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if got != tt.expect {
+			t.Errorf("policy %d: expect '%s' but '%s'", tt.policy, tt.expect, got)
+		}
+	}
+
+	asked := 0
+	o := &Overwriter{
+		Policy: OverwritePrompt,
+		Prompt: func(string) (OverwritePolicy, bool, error) {
+			asked++
+			return OverwriteNever, true, nil
+		},
+	}
+	for i := 0; i < 2; i++ {
+		if got, err := o.Target(name, stamp); err != nil || got != "" {
+			t.Fatalf("expect skipped, but '%s' %v", got, err)
+		}
+	}
+	if asked != 1 {
+		t.Fatalf("expect asked once for [N]one, but %d times", asked)
+	}
+}
@@ -0,0 +1,168 @@
+package uncozip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"unicode/utf8"
+)
+
+const (
+	localFileHeaderSize = sigSize + 26
+
+	// recoverMaxNameLength is the longest filename accepted as plausible in the recovery mode.
+	recoverMaxNameLength = 4096
+)
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// offset returns the position in the input stream which will be read next.
+func (cz *CorruptedZip) offset() int64 {
+	return cz.counter.n - int64(cz.br.Buffered())
+}
+
+// RegisterRecoveryHandler enables the recovery mode.
+// In the recovery mode, when the data where a local file header should be
+// is broken, the Scan method searches for the next plausible local file
+// header and continues from it. f is called with the offsets of the skipped
+// range [start,end) in the input stream. f may be nil.
+func (cz *CorruptedZip) RegisterRecoveryHandler(f func(start, end int64)) {
+	cz.recovery = true
+	if f == nil {
+		f = func(int64, int64) {}
+	}
+	cz.onSkip = f
+}
+
+func (cz *CorruptedZip) knownMethod(method uint16) bool {
+	if _, ok := cz.decompressors[method]; ok {
+		return true
+	}
+	decompressorsMu.RLock()
+	defer decompressorsMu.RUnlock()
+	if _, ok := decompressors[method]; ok {
+		return true
+	}
+	if _, ok := entryDecompressors[method]; ok {
+		return true
+	}
+	return method == WinZipAES
+}
+
+// plausibleLocalFileHeader checks the local file header and the filename in b.
+// It returns 0 when b is plausible, the size required to check when b is too short,
+// and -1 when b is not plausible.
+func (cz *CorruptedZip) plausibleLocalFileHeader(b []byte) int {
+	if len(b) < localFileHeaderSize {
+		return localFileHeaderSize
+	}
+	if !bytes.HasPrefix(b, sigLocalFileHeader) {
+		return -1
+	}
+	var h _LocalFileHeader
+	binary.Read(bytes.NewReader(b[sigSize:]), binary.LittleEndian, &h)
+
+	if version, host := h.RequiredVersion&0xFF, h.RequiredVersion>>8; version > 63 || host > 19 {
+		return -1
+	}
+	if !cz.knownMethod(h.Method) {
+		return -1
+	}
+	if _, month, day := h.date(); h.ModifiedDate != 0 && (month < 1 || month > 12 || day < 1) {
+		return -1
+	}
+	if h.FilenameLength == 0 || h.FilenameLength > recoverMaxNameLength {
+		return -1
+	}
+	if len(b) < localFileHeaderSize+int(h.FilenameLength) {
+		return localFileHeaderSize + int(h.FilenameLength)
+	}
+	name := b[localFileHeaderSize : localFileHeaderSize+int(h.FilenameLength)]
+	for _, c := range name {
+		if c < 0x20 || c == 0x7F {
+			return -1
+		}
+	}
+	if (h.Bits&bitEncodedUTF8) != 0 && !utf8.Valid(name) {
+		return -1
+	}
+	return 0
+}
+
+// resync skips the broken data until the next plausible local file header
+// or the central directory header. It returns io.EOF when the input ends.
+func (cz *CorruptedZip) resync() error {
+	start := cz.offset()
+	defer func() {
+		cz.onSkip(start, cz.offset())
+	}()
+	for {
+		if _, err := cz.br.Discard(1); err != nil {
+			return io.EOF
+		}
+		sig, err := cz.br.Peek(sigSize)
+		if err != nil {
+			cz.br.Discard(len(sig))
+			return io.EOF
+		}
+		if bytes.Equal(sig, sigCentralDirectoryHeader) {
+			return nil
+		}
+		if !bytes.Equal(sig, sigLocalFileHeader) {
+			continue
+		}
+		size := localFileHeaderSize
+		for size > 0 {
+			b, err := cz.br.Peek(size)
+			size = cz.plausibleLocalFileHeader(b)
+			if size > 0 && err != nil {
+				size = -1
+			}
+		}
+		if size == 0 {
+			cz.Debug("Recovery: found a plausible local file header at", cz.offset())
+			return nil
+		}
+	}
+}
+
+// rescan returns the CorruptedZip to search for the entries in r, the data
+// read for the broken entry whose local header is at headerOffset and whose
+// data starts at dataOffset. The range of the broken entry is given to the
+// recovery handler from headerOffset.
+func (cz *CorruptedZip) rescan(r io.Reader, headerOffset, dataOffset int64) *CorruptedZip {
+	sub := New(r)
+	sub.counter.n = dataOffset
+	sub.Debug = cz.Debug
+	sub.fnameDecoder = cz.fnameDecoder
+	sub.extraFieldHandlers = cz.extraFieldHandlers
+	sub.rawMode = cz.rawMode
+	sub.recovery = true
+	sub.onSkip = func(start, end int64) {
+		if start == dataOffset {
+			start = headerOffset
+		}
+		cz.onSkip(start, end)
+	}
+	return sub
+}
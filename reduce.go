@@ -0,0 +1,124 @@
+package uncozip
+
+import (
+	"io"
+)
+
+// Reduce - Methods 2 to 5 compress data with the probabilistic
+// "follower sets" and a simple LZ77 whose matches start with DLE(144).
+// The method number minus one is the compression factor.
+
+const reduceDLE = 144
+
+type reduceReader struct {
+	legacyReader
+	factor    uint
+	followers [256][]byte
+	ready     bool
+	lastChar  byte
+	state     int
+	length    int
+	value     byte
+}
+
+func newReduceReader(method uint16) func(io.Reader, uint16, int64) io.ReadCloser {
+	return func(r io.Reader, bits uint16, size int64) io.ReadCloser {
+		z := &reduceReader{factor: uint(method - Reduce1 + 1)}
+		z.init(r, size, z.step)
+		return z
+	}
+}
+
+// followerBits returns the number of bits to encode an index of n followers.
+func followerBits(n int) uint {
+	bits := uint(1)
+	for (1 << bits) < n {
+		bits++
+	}
+	return bits
+}
+
+func (z *reduceReader) readFollowers() error {
+	for j := 255; j >= 0; j-- {
+		n, err := z.getBits(6)
+		if err != nil {
+			return err
+		}
+		z.followers[j] = make([]byte, n)
+		for i := range z.followers[j] {
+			c, err := z.getBits(8)
+			if err != nil {
+				return err
+			}
+			z.followers[j][i] = byte(c)
+		}
+	}
+	return nil
+}
+
+func (z *reduceReader) readChar() (byte, error) {
+	followers := z.followers[z.lastChar]
+	if len(followers) > 0 {
+		bit, err := z.getBits(1)
+		if err != nil {
+			return 0, err
+		}
+		if bit == 0 {
+			i, err := z.getBits(followerBits(len(followers)))
+			if err != nil {
+				return 0, err
+			}
+			if int(i) >= len(followers) {
+				return 0, ErrLegacyData
+			}
+			return followers[i], nil
+		}
+	}
+	c, err := z.getBits(8)
+	return byte(c), err
+}
+
+func (z *reduceReader) step() error {
+	if !z.ready {
+		if err := z.readFollowers(); err != nil {
+			return err
+		}
+		z.ready = true
+	}
+	c, err := z.readChar()
+	if err != nil {
+		return err
+	}
+	z.lastChar = c
+
+	mask := byte(0x7F >> (z.factor - 1))
+	switch z.state {
+	case 0:
+		if c == reduceDLE {
+			z.state = 1
+		} else {
+			z.put(c)
+		}
+	case 1:
+		if c == 0 {
+			z.put(reduceDLE)
+			z.state = 0
+		} else {
+			z.value = c
+			z.length = int(c & mask)
+			if c&mask == mask {
+				z.state = 2
+			} else {
+				z.state = 3
+			}
+		}
+	case 2:
+		z.length += int(c)
+		z.state = 3
+	case 3:
+		dist := int(z.value>>(8-z.factor))*256 + int(c) + 1
+		z.copyMatch(dist, z.length+3)
+		z.state = 0
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package uncozip
+
+import (
+	"io"
+	"sync"
+)
+
+// Decompressor returns a reader that decompresses r.
+// It is the same as archive/zip.Decompressor.
+type Decompressor func(r io.Reader) io.ReadCloser
+
+var (
+	decompressorsMu         sync.RWMutex
+	registeredDecompressors = map[uint16]Decompressor{}
+)
+
+// RegisterDecompressor registers or overrides the decompressor for the method
+// for all instances of CorruptedZip. The built-in decompressor for the method
+// is used again after dcomp is unregistered with nil.
+func RegisterDecompressor(method uint16, dcomp Decompressor) {
+	decompressorsMu.Lock()
+	defer decompressorsMu.Unlock()
+	if dcomp == nil {
+		delete(registeredDecompressors, method)
+		return
+	}
+	registeredDecompressors[method] = dcomp
+}
+
+// RegisterDecompressor registers or overrides the decompressor for the method
+// only for this instance. It has priority over the ones registered with
+// the function RegisterDecompressor.
+func (cz *CorruptedZip) RegisterDecompressor(method uint16, dcomp Decompressor) {
+	if cz.decompressors == nil {
+		cz.decompressors = make(map[uint16]Decompressor)
+	}
+	cz.decompressors[method] = dcomp
+}
+
+// ExtraField is an extra field of the local file header.
+type ExtraField struct {
+	ID   uint16
+	Data []byte // the payload without the ID and the size
+}
+
+// ExtraFieldHandler is called with the payload of the extra field while
+// the local file header is read. The fields which this package knows
+// (ZIP64, timestamps and so on) are parsed before the handler is called.
+// The returned error stops reading the archive.
+type ExtraFieldHandler func(cz *CorruptedZip, data []byte) error
+
+var (
+	extraFieldHandlersMu sync.RWMutex
+	extraFieldHandlers   = map[uint16]ExtraFieldHandler{}
+)
+
+// RegisterExtraField registers the handler for the extra field whose header ID is id
+// for all instances of CorruptedZip.
+func RegisterExtraField(id uint16, f ExtraFieldHandler) {
+	extraFieldHandlersMu.Lock()
+	defer extraFieldHandlersMu.Unlock()
+	extraFieldHandlers[id] = f
+}
+
+// RegisterExtraField registers the handler for the extra field whose header ID is id
+// only for this instance. It has priority over the ones registered with
+// the function RegisterExtraField.
+func (cz *CorruptedZip) RegisterExtraField(id uint16, f ExtraFieldHandler) {
+	if cz.extraFieldHandlers == nil {
+		cz.extraFieldHandlers = make(map[uint16]ExtraFieldHandler)
+	}
+	cz.extraFieldHandlers[id] = f
+}
+
+func (cz *CorruptedZip) extraFieldHandler(id uint16) ExtraFieldHandler {
+	if f, ok := cz.extraFieldHandlers[id]; ok {
+		return f
+	}
+	extraFieldHandlersMu.RLock()
+	defer extraFieldHandlersMu.RUnlock()
+	return extraFieldHandlers[id]
+}
+
+// ExtraFields returns all extra fields in the local file header of the most recent file.
+func (cz *CorruptedZip) ExtraFields() []ExtraField {
+	return cz.extraFields
+}
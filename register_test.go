@@ -0,0 +1,136 @@
+package uncozip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newUpperReader is a toy decompressor which converts the data to upper case.
+func newUpperReader(r io.Reader) io.ReadCloser {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return &errorReader{err: err}
+	}
+	return io.NopCloser(bytes.NewReader(bytes.ToUpper(data)))
+}
+
+func TestRegisterDecompressor(t *testing.T) {
+	const method = 0xF0
+	expect := "HELLO, DECOMPRESSOR"
+	var archive bytes.Buffer
+	archive.Write(makeEntry("hello.txt", method, []byte(strings.ToLower(expect)),
+		crc32.ChecksumIEEE([]byte(expect)), uint32(len(expect)), false))
+	archive.Write(sigCentralDirectoryHeader)
+
+	cz := New(bytes.NewReader(archive.Bytes()))
+	if !cz.Scan() {
+		t.Fatalf("Scan failed: %v", cz.Err())
+	}
+	if _, err := io.ReadAll(cz.Body()); err == nil {
+		t.Fatal("expect an error for the unknown method, but Body succeeded")
+	}
+
+	cz = New(bytes.NewReader(archive.Bytes()))
+	cz.RegisterDecompressor(method, newUpperReader)
+	if !cz.Scan() {
+		t.Fatalf("Scan failed: %v", cz.Err())
+	}
+	var output strings.Builder
+	if _, err := io.Copy(&output, cz.Body()); err != nil {
+		t.Fatal(err.Error())
+	}
+	if out := output.String(); out != expect {
+		t.Fatalf("Body: expect '%s' but '%s'", expect, out)
+	}
+
+	RegisterDecompressor(method, newUpperReader)
+	defer RegisterDecompressor(method, nil)
+	testReadEntry(t, archive.Bytes(), expect)
+}
+
+func TestRegisterDecompressorForBuiltin(t *testing.T) {
+	var archive bytes.Buffer
+	archive.Write(makeEntry("implode.txt", Implode, implodeData,
+		crc32.ChecksumIEEE([]byte(implodeExpect)), uint32(len(implodeExpect)), false))
+	archive.Write(sigCentralDirectoryHeader)
+
+	RegisterDecompressor(Implode, func(io.Reader) io.ReadCloser {
+		return io.NopCloser(strings.NewReader("overridden"))
+	})
+	cz := New(bytes.NewReader(archive.Bytes()))
+	if !cz.Scan() {
+		t.Fatalf("Scan failed: %v", cz.Err())
+	}
+	if output, _ := io.ReadAll(cz.Body()); string(output) != "overridden" {
+		t.Fatalf("expect the registered decompressor, but '%s'", output)
+	}
+
+	// The built-in decompressor is not lost.
+	RegisterDecompressor(Implode, nil)
+	testReadEntry(t, archive.Bytes(), implodeExpect)
+}
+
+func TestRegisterExtraField(t *testing.T) {
+	const idBuild = 0xCAFE
+	var extra bytes.Buffer
+	binary.Write(&extra, binary.LittleEndian, []uint16{idStamp, 5})
+	extra.Write([]byte{1, 1, 0, 0, 0})
+	binary.Write(&extra, binary.LittleEndian, []uint16{idBuild, 8})
+	extra.WriteString("build-42")
+
+	const name = "hello.txt"
+	var archive bytes.Buffer
+	archive.Write(sigLocalFileHeader)
+	binary.Write(&archive, binary.LittleEndian, &_LocalFileHeader{
+		RequiredVersion: 20,
+		FilenameLength:  uint16(len(name)),
+		ExtendFieldSize: uint16(extra.Len()),
+	})
+	archive.WriteString(name)
+	archive.Write(extra.Bytes())
+	archive.Write(sigCentralDirectoryHeader)
+
+	var global, local string
+	RegisterExtraField(idBuild, func(cz *CorruptedZip, data []byte) error {
+		global = string(data)
+		return nil
+	})
+	defer func() {
+		extraFieldHandlersMu.Lock()
+		delete(extraFieldHandlers, idBuild)
+		extraFieldHandlersMu.Unlock()
+	}()
+
+	cz := New(bytes.NewReader(archive.Bytes()))
+	if !cz.Scan() {
+		t.Fatalf("Scan failed: %v", cz.Err())
+	}
+	if global != "build-42" {
+		t.Fatalf("global handler: expect 'build-42' but '%s'", global)
+	}
+	fields := cz.ExtraFields()
+	if len(fields) != 2 || fields[0].ID != idStamp || fields[1].ID != idBuild || string(fields[1].Data) != "build-42" {
+		t.Fatalf("ExtraFields: unexpected %v", fields)
+	}
+	if !cz.LastModificationTime.Equal(time.Unix(1, 0)) {
+		t.Fatalf("LastModificationTime: expect %v but %v", time.Unix(1, 0), cz.LastModificationTime)
+	}
+
+	global = ""
+	cz = New(bytes.NewReader(archive.Bytes()))
+	cz.RegisterExtraField(idBuild, func(cz *CorruptedZip, data []byte) error {
+		local = string(data)
+		return nil
+	})
+	if !cz.Scan() {
+		t.Fatalf("Scan failed: %v", cz.Err())
+	}
+	if local != "build-42" || global != "" {
+		t.Fatalf("expect only the handler of the instance called, but global='%s' local='%s'", global, local)
+	}
+}
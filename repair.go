@@ -0,0 +1,393 @@
+package uncozip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+)
+
+var (
+	sigZIP64EndOfCentralDirectory        = []byte{'P', 'K', 6, 6}
+	sigZIP64EndOfCentralDirectoryLocator = []byte{'P', 'K', 6, 7}
+	sigEndOfCentralDirectory             = []byte{'P', 'K', 5, 6}
+)
+
+const (
+	zip64Version   = 45
+	defaultVersion = 20
+
+	msdosDirAttribute = 0x10
+)
+
+type _CentralDirectoryHeader struct {
+	CreatorVersion        uint16
+	RequiredVersion       uint16
+	Bits                  uint16
+	Method                uint16
+	ModifiedTime          uint16
+	ModifiedDate          uint16
+	CRC32                 uint32
+	CompressedSize        uint32
+	UncompressedSize      uint32
+	FilenameLength        uint16
+	ExtendFieldSize       uint16
+	CommentLength         uint16
+	DiskNumberStart       uint16
+	InternalAttributes    uint16
+	ExternalAttributes    uint32
+	LocalFileHeaderOffset uint32
+}
+
+type _ZIP64EndOfCentralDirectory struct {
+	Size                 uint64
+	CreatorVersion       uint16
+	RequiredVersion      uint16
+	DiskNumber           uint32
+	CentralDirectoryDisk uint32
+	EntriesOnDisk        uint64
+	Entries              uint64
+	CentralDirectorySize uint64
+	CentralDirectory     uint64
+}
+
+type _ZIP64EndOfCentralDirectoryLocator struct {
+	Disk       uint32
+	Offset     uint64
+	TotalDisks uint32
+}
+
+type _EndOfCentralDirectory struct {
+	DiskNumber           uint16
+	CentralDirectoryDisk uint16
+	EntriesOnDisk        uint16
+	Entries              uint16
+	CentralDirectorySize uint32
+	CentralDirectory     uint32
+	CommentLength        uint16
+}
+
+type repairedEntry struct {
+	header           _LocalFileHeader
+	name             []byte
+	extra            []byte // the extra fields except ZIP64
+	crc32            uint32
+	compressedSize   uint64
+	uncompressedSize uint64
+	offset           uint64
+	isDir            bool
+	mode             *fs.FileMode // the Unix mode from the extra field
+	data             *Spool       // the raw data until it is written
+}
+
+func (e *repairedEntry) zip64() bool {
+	return e.compressedSize >= math.MaxUint32 || e.uncompressedSize >= math.MaxUint32
+}
+
+func (e *repairedEntry) requiredVersion() uint16 {
+	v := e.header.RequiredVersion & 0xFF
+	if e.zip64() || e.offset >= math.MaxUint32 {
+		v = max(v, zip64Version)
+	}
+	return max(v, defaultVersion)
+}
+
+// Repair copies the entries of cz into w as a valid ZIP archive.
+// The compressed data of each entry is copied as it is (without
+// decompression and decryption), the local file header is rewritten with
+// the CRC32 and the sizes learned from the data descriptor, and a new central
+// directory is written. ZIP64 records are used when needed.
+// DelimitByDecompressor of cz is ignored.
+//
+// When reading an entry fails, the entry is dropped and the central directory
+// of the entries written already is still written. Then the error is
+// returned with the number of the entries written. In the recovery mode
+// (see RegisterRecoveryHandler), the broken entry is dropped instead and the
+// next entries are searched for in the data read for it.
+func Repair(cz *CorruptedZip, w io.Writer) (int, error) {
+	cw := &countingWriter{w: w}
+	cz.rawMode = true
+	defer func() { cz.rawMode = false }()
+
+	var entries []*repairedEntry
+	var readErr error
+	for source := cz; source != nil; {
+		var broken *repairedEntry
+		var headerOffset, dataOffset int64
+		for entry := range source.Each {
+			e, err := readRepairedEntry(entry)
+			if err == nil {
+				if err = verifyRepairedEntry(entry, e); err != nil && cz.recovery {
+					cz.Debug("Repair: drop", entry.Name(), err)
+					broken = e
+					headerOffset = entry.LocalHeaderOffset()
+					dataOffset = entry.DataOffset()
+					break
+				}
+			}
+			if err != nil {
+				if e != nil && e.data != nil {
+					e.data.Close()
+				}
+				readErr = fmt.Errorf("%s: %w", entry.Name(), err)
+				break
+			}
+			e.offset = uint64(cw.n)
+			err = writeRepairedEntry(cw, e)
+			if e.data != nil {
+				e.data.Close()
+				e.data = nil
+			}
+			if err != nil {
+				return len(entries), err
+			}
+			entries = append(entries, e)
+		}
+		if broken == nil {
+			if readErr == nil {
+				if err := source.Err(); err != nil && err != io.EOF {
+					readErr = err
+				}
+			}
+			if readErr == nil {
+				modesFromCentralDirectory(source, entries)
+			}
+			break
+		}
+		r, err := broken.data.Open()
+		if err != nil {
+			broken.data.Close()
+			return len(entries), err
+		}
+		defer broken.data.Close()
+		defer r.Close()
+		source = cz.rescan(r, headerOffset, dataOffset)
+	}
+	if err := writeCentralDirectory(cw, entries); err != nil {
+		return len(entries), err
+	}
+	return len(entries), readErr
+}
+
+// modesFromCentralDirectory fills the Unix modes which are not found in the
+// local headers with the original central directory if it remains.
+func modesFromCentralDirectory(cz *CorruptedZip, entries []*repairedEntry) {
+	cd, _ := cz.ReadCentralDirectory()
+	modes := make(map[string]fs.FileMode, len(cd))
+	for _, c := range cd {
+		if mode, ok := c.Mode(); ok {
+			modes[string(c.RawName)] = mode
+		}
+	}
+	for _, e := range entries {
+		if mode, ok := modes[string(e.name)]; ok && e.mode == nil {
+			e.mode = &mode
+		}
+	}
+}
+
+// readRepairedEntry reads the header and the raw data of the most recent file.
+func readRepairedEntry(cz *CorruptedZip) (*repairedEntry, error) {
+	e := &repairedEntry{
+		header: cz.header,
+		name:   cz.rawName,
+		isDir:  cz.IsDir(),
+	}
+	if mode, ok := cz.Mode(); ok {
+		e.mode = &mode
+	}
+	var extra bytes.Buffer
+	for _, f := range cz.extraFields {
+		if f.ID == idZIP64 {
+			continue
+		}
+		binary.Write(&extra, binary.LittleEndian, [2]uint16{f.ID, uint16(len(f.Data))})
+		extra.Write(f.Data)
+	}
+	e.extra = extra.Bytes()
+
+	if e.isDir {
+		return e, nil
+	}
+	s, err := NewSpool(cz.raw, DefaultSpoolMemLimit, "")
+	if err != nil {
+		return nil, err
+	}
+	e.data = s
+	e.crc32 = cz.CRC32()
+	e.compressedSize = uint64(s.Size())
+	e.uncompressedSize = cz.OriginalSize()
+	return e, nil
+}
+
+// verifyRepairedEntry checks that the data of e was read as the header or
+// the data descriptor tells.
+func verifyRepairedEntry(cz *CorruptedZip, e *repairedEntry) error {
+	if e.isDir {
+		return nil
+	}
+	if err := cz.bgErr(); err != nil {
+		return err
+	}
+	if cz.HasDataDescriptor() && cz.DataDescriptorOffset() < 0 {
+		return errors.New("data descriptor not found")
+	}
+	if e.compressedSize != cz.CompressedSize() {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+func writeRepairedEntry(w io.Writer, e *repairedEntry) error {
+	h := e.header
+	h.RequiredVersion = e.requiredVersion()
+	// The data descriptor is kept for ZipCrypto because the bit 3 changes
+	// the check byte of the encryption header.
+	keepDataDescriptor := (h.Bits&bitDataDescriptorUsed) != 0 &&
+		(h.Bits&bitEncrypted) != 0 && h.Method != WinZipAES
+	if !keepDataDescriptor {
+		h.Bits &^= bitDataDescriptorUsed
+	}
+	h.CRC32 = e.crc32
+	extra := e.extra
+	if e.zip64() {
+		h.CompressedSize = math.MaxUint32
+		h.UncompressedSize = math.MaxUint32
+		var zip64 bytes.Buffer
+		binary.Write(&zip64, binary.LittleEndian, [2]uint16{idZIP64, 16})
+		binary.Write(&zip64, binary.LittleEndian, [2]uint64{e.uncompressedSize, e.compressedSize})
+		extra = append(zip64.Bytes(), extra...)
+	} else {
+		h.CompressedSize = uint32(e.compressedSize)
+		h.UncompressedSize = uint32(e.uncompressedSize)
+	}
+	h.FilenameLength = uint16(len(e.name))
+	h.ExtendFieldSize = uint16(len(extra))
+
+	var buffer bytes.Buffer
+	buffer.Write(sigLocalFileHeader)
+	binary.Write(&buffer, binary.LittleEndian, &h)
+	buffer.Write(e.name)
+	buffer.Write(extra)
+	if _, err := buffer.WriteTo(w); err != nil {
+		return err
+	}
+	if e.data != nil {
+		if _, err := e.data.WriteTo(w); err != nil {
+			return err
+		}
+	}
+	if !keepDataDescriptor {
+		return nil
+	}
+	buffer.Write(sigDataDescriptor)
+	if e.zip64() {
+		binary.Write(&buffer, binary.LittleEndian, &_DataDescriptor64{
+			CRC32:            e.crc32,
+			CompressedSize:   e.compressedSize,
+			UncompressedSize: e.uncompressedSize,
+		})
+	} else {
+		binary.Write(&buffer, binary.LittleEndian, &_DataDescriptor{
+			CRC32:            e.crc32,
+			CompressedSize:   uint32(e.compressedSize),
+			UncompressedSize: uint32(e.uncompressedSize),
+		})
+	}
+	_, err := buffer.WriteTo(w)
+	return err
+}
+
+func writeCentralDirectory(w *countingWriter, entries []*repairedEntry) error {
+	start := uint64(w.n)
+	var buffer bytes.Buffer
+	for _, e := range entries {
+		h := _CentralDirectoryHeader{
+			CreatorVersion:  e.requiredVersion(),
+			RequiredVersion: e.requiredVersion(),
+			Bits:            e.header.Bits,
+			Method:          e.header.Method,
+			ModifiedTime:    e.header.ModifiedTime,
+			ModifiedDate:    e.header.ModifiedDate,
+			CRC32:           e.crc32,
+		}
+		if (h.Bits&bitEncrypted) == 0 || h.Method == WinZipAES {
+			h.Bits &^= bitDataDescriptorUsed
+		}
+		if e.isDir {
+			h.ExternalAttributes = msdosDirAttribute
+		}
+		if e.mode != nil {
+			h.CreatorVersion = hostUnix<<8 | h.CreatorVersion
+			h.ExternalAttributes |= fileModeToUnixMode(*e.mode) << 16
+		}
+		var zip64 []uint64
+		if e.uncompressedSize >= math.MaxUint32 || e.compressedSize >= math.MaxUint32 {
+			h.UncompressedSize = math.MaxUint32
+			h.CompressedSize = math.MaxUint32
+			zip64 = append(zip64, e.uncompressedSize, e.compressedSize)
+		} else {
+			h.UncompressedSize = uint32(e.uncompressedSize)
+			h.CompressedSize = uint32(e.compressedSize)
+		}
+		if e.offset >= math.MaxUint32 {
+			h.LocalFileHeaderOffset = math.MaxUint32
+			zip64 = append(zip64, e.offset)
+		} else {
+			h.LocalFileHeaderOffset = uint32(e.offset)
+		}
+		var extra bytes.Buffer
+		if len(zip64) > 0 {
+			binary.Write(&extra, binary.LittleEndian, [2]uint16{idZIP64, uint16(8 * len(zip64))})
+			binary.Write(&extra, binary.LittleEndian, zip64)
+		}
+		extra.Write(e.extra)
+		h.FilenameLength = uint16(len(e.name))
+		h.ExtendFieldSize = uint16(extra.Len())
+
+		buffer.Write(sigCentralDirectoryHeader)
+		binary.Write(&buffer, binary.LittleEndian, &h)
+		buffer.Write(e.name)
+		buffer.Write(extra.Bytes())
+		if _, err := buffer.WriteTo(w); err != nil {
+			return err
+		}
+	}
+	end := uint64(w.n)
+	size := end - start
+
+	eocd := _EndOfCentralDirectory{
+		EntriesOnDisk:        uint16(len(entries)),
+		Entries:              uint16(len(entries)),
+		CentralDirectorySize: uint32(size),
+		CentralDirectory:     uint32(start),
+	}
+	if len(entries) >= math.MaxUint16 || size >= math.MaxUint32 || start >= math.MaxUint32 {
+		buffer.Write(sigZIP64EndOfCentralDirectory)
+		binary.Write(&buffer, binary.LittleEndian, &_ZIP64EndOfCentralDirectory{
+			Size:                 44,
+			CreatorVersion:       zip64Version,
+			RequiredVersion:      zip64Version,
+			EntriesOnDisk:        uint64(len(entries)),
+			Entries:              uint64(len(entries)),
+			CentralDirectorySize: size,
+			CentralDirectory:     start,
+		})
+		buffer.Write(sigZIP64EndOfCentralDirectoryLocator)
+		binary.Write(&buffer, binary.LittleEndian, &_ZIP64EndOfCentralDirectoryLocator{
+			Offset:     end,
+			TotalDisks: 1,
+		})
+		eocd.EntriesOnDisk = math.MaxUint16
+		eocd.Entries = math.MaxUint16
+		eocd.CentralDirectorySize = math.MaxUint32
+		eocd.CentralDirectory = math.MaxUint32
+	}
+	buffer.Write(sigEndOfCentralDirectory)
+	binary.Write(&buffer, binary.LittleEndian, &eocd)
+	_, err := buffer.WriteTo(w)
+	return err
+}
@@ -0,0 +1,183 @@
+package uncozip
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRepair(t *testing.T) {
+	files := []struct {
+		name   string
+		method uint16
+		data   string
+		dd     bool
+	}{
+		{"dir/", Store, "", false},
+		{"dir/stored.txt", Store, "stored data", true},
+		{"dir/deflated.txt", Deflate, strings.Repeat("deflated data\n", 100), true},
+		{"plain.txt", Store, "plain", false},
+	}
+	var archive bytes.Buffer
+	for _, f := range files {
+		data := []byte(f.data)
+		if f.method == Deflate {
+			var compressed bytes.Buffer
+			w, _ := flate.NewWriter(&compressed, flate.BestCompression)
+			w.Write(data)
+			w.Close()
+			data = compressed.Bytes()
+		}
+		archive.Write(makeEntry(f.name, f.method, data, crc32.ChecksumIEEE([]byte(f.data)), uint32(len(f.data)), f.dd))
+	}
+	complete := archive.Len()
+	archive.Write(sigCentralDirectoryHeader)
+
+	var output bytes.Buffer
+	n, err := Repair(New(bytes.NewReader(archive.Bytes())), &output)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if n != len(files) {
+		t.Fatalf("expect %d entries, but %d", len(files), n)
+	}
+	z, err := zip.NewReader(bytes.NewReader(output.Bytes()), int64(output.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(z.File) != len(files) {
+		t.Fatalf("expect %d files, but %d", len(files), len(z.File))
+	}
+	for i, f := range z.File {
+		if f.Name != files[i].name {
+			t.Fatalf("expect '%s' but '%s'", files[i].name, f.Name)
+		}
+		if (f.Flags & bitDataDescriptorUsed) != 0 {
+			t.Fatalf("%s: expect the data descriptor removed", f.Name)
+		}
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("%s: %s", f.Name, err.Error())
+		}
+		if string(data) != files[i].data {
+			t.Fatalf("%s: expect '%s' but '%s'", f.Name, files[i].data, data)
+		}
+	}
+
+	// The archive truncated in the last entry
+	output.Reset()
+	n, err = Repair(New(bytes.NewReader(archive.Bytes()[:complete-2])), &output)
+	if err == nil {
+		t.Fatal("expect an error for the truncated entry")
+	}
+	if n != len(files)-1 {
+		t.Fatalf("expect %d entries, but %d", len(files)-1, n)
+	}
+	z, err = zip.NewReader(bytes.NewReader(output.Bytes()), int64(output.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(z.File) != len(files)-1 {
+		t.Fatalf("expect %d files, but %d", len(files)-1, len(z.File))
+	}
+}
+
+func TestRepairZIP64CentralDirectory(t *testing.T) {
+	const huge = 5 << 30
+	var output bytes.Buffer
+	entries := []*repairedEntry{
+		{
+			header:           _LocalFileHeader{RequiredVersion: 20},
+			name:             []byte("huge.bin"),
+			compressedSize:   huge,
+			uncompressedSize: huge,
+			offset:           huge,
+		},
+	}
+	if err := writeCentralDirectory(&countingWriter{w: &output}, entries); err != nil {
+		t.Fatal(err.Error())
+	}
+	z, err := zip.NewReader(bytes.NewReader(output.Bytes()), int64(output.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(z.File) != 1 || z.File[0].UncompressedSize64 != huge || z.File[0].CompressedSize64 != huge {
+		t.Fatalf("unexpected central directory: %+v", z.File[0].FileHeader)
+	}
+}
+
+func TestRepairUnixMode(t *testing.T) {
+	data := []byte("#!/bin/sh\n")
+	entry := makeEntry("run.sh", Store, data, crc32.ChecksumIEEE(data), uint32(len(data)), true)
+	var extra bytes.Buffer
+	binary.Write(&extra, binary.LittleEndian, [2]uint16{idASiUnix, 14})
+	binary.Write(&extra, binary.LittleEndian, &_ASiUnix{Mode: unixRegular | 0755})
+
+	var archive bytes.Buffer
+	archive.Write(insertExtraField(entry, len("run.sh"), extra.Bytes()))
+	archive.Write(sigCentralDirectoryHeader)
+
+	var output bytes.Buffer
+	if _, err := Repair(New(bytes.NewReader(archive.Bytes())), &output); err != nil {
+		t.Fatal(err.Error())
+	}
+	z, err := zip.NewReader(bytes.NewReader(output.Bytes()), int64(output.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if mode := z.File[0].Mode(); mode != 0755 {
+		t.Fatalf("expect 0755, but %v", mode)
+	}
+}
+
+func TestRepairRecovery(t *testing.T) {
+	var archive bytes.Buffer
+	var brokenRange [2]int64
+	for _, name := range []string{"first.txt", "broken.txt", "last.txt"} {
+		data := []byte(name)
+		entry := makeEntry(name, Store, data, crc32.ChecksumIEEE(data), uint32(len(data)), true)
+		if name == "broken.txt" {
+			entry = entry[:len(entry)-16] // without the data descriptor
+			brokenRange = [2]int64{int64(archive.Len()), int64(archive.Len() + len(entry))}
+		}
+		archive.Write(entry)
+	}
+	archive.Write(sigCentralDirectoryHeader)
+
+	var output bytes.Buffer
+	n, err := Repair(New(bytes.NewReader(archive.Bytes())), &output)
+	if err == nil || n != 1 {
+		t.Fatalf("expect an error after 1 entry, but %d entries and %v", n, err)
+	}
+
+	output.Reset()
+	cz := New(bytes.NewReader(archive.Bytes()))
+	var skipped [][2]int64
+	cz.RegisterRecoveryHandler(func(start, end int64) {
+		skipped = append(skipped, [2]int64{start, end})
+	})
+	n, err = Repair(cz, &output)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if n != 2 || len(skipped) != 1 || skipped[0] != brokenRange {
+		t.Fatalf("expect 2 entries and the skipped range %v, but %d and %v", brokenRange, n, skipped)
+	}
+	z, err := zip.NewReader(bytes.NewReader(output.Bytes()), int64(output.Len()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(z.File) != 2 || z.File[0].Name != "first.txt" || z.File[1].Name != "last.txt" {
+		t.Fatalf("unexpected files %v", z.File)
+	}
+}
@@ -0,0 +1,155 @@
+package uncozip
+
+import (
+	"io"
+)
+
+// Shrink - Method 1 is a dynamic LZW with the code size from 9 to 13 bits.
+// The code 256 is followed by the control code: 1 increases the code size
+// and 2 clears all codes which are not used as a prefix (partial clearing).
+
+const (
+	shrinkMinCodeSize  = 9
+	shrinkMaxCodeSize  = 13
+	shrinkMaxCode      = 1<<shrinkMaxCodeSize - 1
+	shrinkControlCode  = 256
+	shrinkIncCodeSize  = 1
+	shrinkPartialClear = 2
+	shrinkInvalidCode  = -1
+)
+
+type _ShrinkCode struct {
+	prefix int
+	ext    byte
+}
+
+type shrinkReader struct {
+	legacyReader
+	codeSize uint
+	table    [shrinkMaxCode + 1]_ShrinkCode
+	free     []int
+	prev     []byte
+	prevCode int
+	first    bool
+	buffer   []byte
+}
+
+func newShrinkReader(r io.Reader, bits uint16, size int64) io.ReadCloser {
+	s := &shrinkReader{
+		codeSize: shrinkMinCodeSize,
+		first:    true,
+		free:     make([]int, 0, shrinkMaxCode),
+	}
+	for i := range s.table {
+		s.table[i].prefix = shrinkInvalidCode
+		if i < 256 {
+			s.table[i].ext = byte(i)
+		}
+	}
+	for i := shrinkControlCode + 1; i <= shrinkMaxCode; i++ {
+		s.free = append(s.free, i)
+	}
+	s.init(r, size, s.step)
+	return s
+}
+
+func (s *shrinkReader) used(code int) bool {
+	return code < 256 || (code > shrinkControlCode && s.table[code].prefix != shrinkInvalidCode)
+}
+
+// expand returns the string which the code stands for.
+func (s *shrinkReader) expand(code int) ([]byte, error) {
+	s.buffer = s.buffer[:0]
+	for n := 0; code >= 256; n++ {
+		if n > shrinkMaxCode || code == shrinkControlCode {
+			return nil, ErrLegacyData
+		}
+		s.buffer = append(s.buffer, s.table[code].ext)
+		code = s.table[code].prefix
+		if code == shrinkInvalidCode {
+			return nil, ErrLegacyData
+		}
+	}
+	s.buffer = append(s.buffer, byte(code))
+	for i, j := 0, len(s.buffer)-1; i < j; i, j = i+1, j-1 {
+		s.buffer[i], s.buffer[j] = s.buffer[j], s.buffer[i]
+	}
+	return s.buffer, nil
+}
+
+func (s *shrinkReader) partialClear() {
+	var isPrefix [shrinkMaxCode + 1]bool
+	for i := shrinkControlCode + 1; i <= shrinkMaxCode; i++ {
+		if p := s.table[i].prefix; p != shrinkInvalidCode {
+			isPrefix[p] = true
+		}
+	}
+	s.free = s.free[:0]
+	for i := shrinkControlCode + 1; i <= shrinkMaxCode; i++ {
+		if !isPrefix[i] {
+			s.table[i].prefix = shrinkInvalidCode
+			s.free = append(s.free, i)
+		}
+	}
+}
+
+func (s *shrinkReader) step() error {
+	c, err := s.getBits(s.codeSize)
+	if err != nil {
+		return err
+	}
+	code := int(c)
+	if code == shrinkControlCode {
+		c, err := s.getBits(s.codeSize)
+		if err != nil {
+			return err
+		}
+		switch c {
+		case shrinkIncCodeSize:
+			if s.codeSize >= shrinkMaxCodeSize {
+				return ErrLegacyData
+			}
+			s.codeSize++
+		case shrinkPartialClear:
+			s.partialClear()
+		default:
+			return ErrLegacyData
+		}
+		return nil
+	}
+	if s.first {
+		if code >= 256 {
+			return ErrLegacyData
+		}
+		s.first = false
+		s.put(byte(code))
+		s.prev = append(s.prev[:0], byte(code))
+		s.prevCode = code
+		return nil
+	}
+	var str []byte
+	if s.used(code) {
+		if str, err = s.expand(code); err != nil {
+			return err
+		}
+	} else {
+		// KwKwK: the code is the one which is being defined now.
+		if len(s.free) <= 0 || code != s.free[0] {
+			return ErrLegacyData
+		}
+		str = append(append(s.buffer[:0], s.prev...), s.prev[0])
+		s.buffer = str
+	}
+	for _, b := range str {
+		s.put(b)
+	}
+	if len(s.free) > 0 {
+		newCode := s.free[0]
+		s.free = s.free[1:]
+		s.table[newCode].ext = str[0]
+		s.table[newCode].prefix = s.prevCode
+	}
+	s.prev = append(s.prev[:0], str...)
+	s.prevCode = code
+	return nil
+}
@@ -0,0 +1,98 @@
+package uncozip
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// DefaultSpoolMemLimit is the size of the data kept in memory by Repair.
+// It is also a reasonable memLimit for NewSpool.
+const DefaultSpoolMemLimit = 1 << 20
+
+// Spool keeps the data read all from a reader so that it can be read again
+// after its size is known. The small data is kept in memory and the large one
+// in a temporary file.
+type Spool struct {
+	data     []byte
+	tempFile string
+	size     int64
+}
+
+// NewSpool reads r all. The data up to memLimit bytes is kept in memory and
+// the larger one is written to a temporary file in tempDir (os.TempDir() when
+// tempDir is ""). Call Close to remove the temporary file.
+func NewSpool(r io.Reader, memLimit int64, tempDir string) (*Spool, error) {
+	var buffer bytes.Buffer
+	n, err := io.CopyN(&buffer, r, memLimit+1)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if n <= memLimit {
+		return &Spool{data: buffer.Bytes(), size: n}, nil
+	}
+	fd, err := os.CreateTemp(tempDir, "uncozip-")
+	if err != nil {
+		return nil, err
+	}
+	m, err := io.Copy(fd, io.MultiReader(&buffer, r))
+	err1 := fd.Close()
+	if err == nil {
+		err = err1
+	}
+	if err != nil {
+		os.Remove(fd.Name())
+		return nil, err
+	}
+	return &Spool{tempFile: fd.Name(), size: m}, nil
+}
+
+// Size returns the size of the data.
+func (s *Spool) Size() int64 {
+	return s.size
+}
+
+// Open returns a new reader of the data. It also implements io.ReaderAt.
+func (s *Spool) Open() (io.ReadSeekCloser, error) {
+	if s.tempFile != "" {
+		return os.Open(s.tempFile)
+	}
+	return bytesReadCloser{bytes.NewReader(s.data)}, nil
+}
+
+// Bytes returns a copy of the data.
+func (s *Spool) Bytes() ([]byte, error) {
+	if s.tempFile != "" {
+		return os.ReadFile(s.tempFile)
+	}
+	return bytes.Clone(s.data), nil
+}
+
+// WriteTo writes the data to w.
+func (s *Spool) WriteTo(w io.Writer) (int64, error) {
+	r, err := s.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	return io.Copy(w, r)
+}
+
+// Close removes the temporary file.
+func (s *Spool) Close() error {
+	s.data = nil
+	if s.tempFile == "" {
+		return nil
+	}
+	err := os.Remove(s.tempFile)
+	s.tempFile = ""
+	return err
+}
+
+type bytesReadCloser struct {
+	*bytes.Reader
+}
+
+func (bytesReadCloser) Close() error {
+	return nil
+}
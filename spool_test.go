@@ -0,0 +1,52 @@
+package uncozip
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSpool(t *testing.T) {
+	for _, size := range []int{16, 100} {
+		expect := strings.Repeat("x", size)
+		s, err := NewSpool(strings.NewReader(expect), 64, t.TempDir())
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if (s.tempFile != "") != (size > 64) {
+			t.Fatalf("%d: unexpected temporary file '%s'", size, s.tempFile)
+		}
+		if s.Size() != int64(size) {
+			t.Fatalf("%d: unexpected size %d", size, s.Size())
+		}
+		for i := 0; i < 2; i++ {
+			var output strings.Builder
+			if _, err := s.WriteTo(&output); err != nil {
+				t.Fatal(err.Error())
+			}
+			if output.String() != expect {
+				t.Fatalf("%d: unexpected '%s'", size, output.String())
+			}
+		}
+		r, err := s.Open()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		r.Seek(int64(size-2), io.SeekStart)
+		if data, _ := io.ReadAll(r); string(data) != "xx" {
+			t.Fatalf("%d: unexpected '%s' after Seek", size, data)
+		}
+		r.Close()
+
+		tempFile := s.tempFile
+		if err := s.Close(); err != nil {
+			t.Fatal(err.Error())
+		}
+		if tempFile != "" {
+			if _, err := os.Stat(tempFile); !os.IsNotExist(err) {
+				t.Fatalf("%d: expect the temporary file removed, but %v", size, err)
+			}
+		}
+	}
+}
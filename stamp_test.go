@@ -0,0 +1,77 @@
+package uncozip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+	"time"
+)
+
+func ntfsExtraField(mtime, atime, ctime time.Time) []byte {
+	filetime := func(t time.Time) uint64 {
+		return uint64(t.UnixNano()/100) + 116444736000000000
+	}
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, [2]uint16{idNTFS, 32})
+	binary.Write(&b, binary.LittleEndian, uint32(0))
+	binary.Write(&b, binary.LittleEndian, [2]uint16{1, 24})
+	binary.Write(&b, binary.LittleEndian, [3]uint64{filetime(mtime), filetime(atime), filetime(ctime)})
+	return b.Bytes()
+}
+
+func extendedTimestamp(mtime time.Time) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, [2]uint16{idStamp, 5})
+	b.WriteByte(1)
+	binary.Write(&b, binary.LittleEndian, uint32(mtime.Unix()))
+	return b.Bytes()
+}
+
+func scanWithExtraField(t *testing.T, extra []byte) *CorruptedZip {
+	t.Helper()
+	data := []byte("stamp")
+	entry := makeEntry("stamp.txt", Store, data, crc32.ChecksumIEEE(data), uint32(len(data)), false)
+	cz := New(bytes.NewReader(insertExtraField(entry, len("stamp.txt"), extra)))
+	if !cz.Scan() {
+		t.Fatalf("Scan failed: %v", cz.Err())
+	}
+	return cz
+}
+
+func TestNTFSTimestamp(t *testing.T) {
+	mtime := time.Date(2024, 5, 6, 7, 8, 9, 123456700, time.UTC)
+	atime := mtime.Add(time.Hour)
+	ctime := mtime.Add(-time.Hour)
+	ntfs := ntfsExtraField(mtime, atime, ctime)
+	unix := extendedTimestamp(mtime.Add(24 * time.Hour))
+
+	// NTFS has the precedence over the extended timestamp in any order.
+	for _, extra := range [][]byte{
+		ntfs,
+		append(append([]byte{}, unix...), ntfs...),
+		append(append([]byte{}, ntfs...), unix...),
+	} {
+		cz := scanWithExtraField(t, extra)
+		if !cz.LastModificationTime.Equal(mtime) {
+			t.Fatalf("LastModificationTime: expect %v, but %v", mtime, cz.LastModificationTime)
+		}
+		if !cz.LastAccessTime.Equal(atime) {
+			t.Fatalf("LastAccessTime: expect %v, but %v", atime, cz.LastAccessTime)
+		}
+		if !cz.CreationTime.Equal(ctime) {
+			t.Fatalf("CreationTime: expect %v, but %v", ctime, cz.CreationTime)
+		}
+	}
+}
+
+func TestExtendedTimestampMissingTimes(t *testing.T) {
+	mtime := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	extra := extendedTimestamp(mtime)
+	extra[4] = 7 // the flags tell all the times, but only mtime exists
+
+	cz := scanWithExtraField(t, extra)
+	if !cz.LastModificationTime.Equal(mtime) {
+		t.Fatalf("LastModificationTime: expect %v, but %v", mtime, cz.LastModificationTime)
+	}
+}
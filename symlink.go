@@ -0,0 +1,96 @@
+package uncozip
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnsafeSymlink is returned by CheckSymlink when the link points outside
+// the extraction directory.
+var ErrUnsafeSymlink = errors.New("symbolic link points outside the directory")
+
+// IsSymlink returns true when the most recent file is known as a symbolic link
+// from the local header. Its body is the target path.
+// It sees only the mode in the extra field of the local header (see Mode).
+// The archives made by Info-ZIP have the modes only in the central directory,
+// so IsSymlink returns false for their links. To find them, call
+// ReadCentralDirectory after the last entry and check CentralDirectoryEntry.Mode
+// of the entry with the same RawName.
+func (cz *CorruptedZip) IsSymlink() bool {
+	mode, ok := cz.Mode()
+	return ok && (mode&fs.ModeSymlink) != 0
+}
+
+// CheckSymlink returns ErrUnsafeSymlink when the symbolic link `name` (the
+// relative path from the directory `root`) to `target` would point outside
+// root. The symbolic links which already exist under root are followed.
+// ".." after a missing path element is treated as unsafe because the element
+// may be created as a symbolic link later.
+func CheckSymlink(root, name, target string) error {
+	target = filepath.FromSlash(target)
+	if target == "" || filepath.IsAbs(target) || filepath.VolumeName(target) != "" ||
+		target[0] == filepath.Separator {
+		return ErrUnsafeSymlink
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		return err
+	}
+	elements := append(splitPath(filepath.Dir(name)), splitPath(target)...)
+
+	current := root
+	missing := false
+	for _, e := range elements {
+		switch e {
+		case "", ".":
+			continue
+		case "..":
+			if missing || current == root {
+				return ErrUnsafeSymlink
+			}
+			current = filepath.Dir(current)
+			continue
+		}
+		next := filepath.Join(current, e)
+		if missing {
+			current = next
+			continue
+		}
+		info, err := os.Lstat(next)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				return err
+			}
+			missing = true
+			current = next
+			continue
+		}
+		if (info.Mode() & fs.ModeSymlink) != 0 {
+			resolved, err := filepath.EvalSymlinks(next)
+			if err != nil || !isInside(root, resolved) {
+				return ErrUnsafeSymlink
+			}
+			next = resolved
+		}
+		current = next
+	}
+	return nil
+}
+
+func splitPath(path string) []string {
+	return strings.FieldsFunc(path, func(c rune) bool {
+		return c == '/' || c == filepath.Separator
+	})
+}
+
+func isInside(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
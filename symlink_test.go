@@ -0,0 +1,45 @@
+package uncozip
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckSymlink(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "sub", "dir"), 0755); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := os.Symlink("..", filepath.Join(root, "sub", "up")); err != nil {
+		t.Skip(err.Error())
+	}
+	if err := os.Symlink("..", filepath.Join(root, "outside")); err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, c := range []struct {
+		name   string
+		target string
+		safe   bool
+	}{
+		{"link", "sub/dir", true},
+		{"sub/dir/link", "../../file", true},
+		{"sub/link", "up/sub", true},
+		{"link", "/etc/passwd", false},
+		{"link", "../file", false},
+		{"sub/link", "../../file", false},
+		{"link", "sub/up/..", false},       // sub/up is the root
+		{"link", "missing/../file", false}, // missing may be a link later
+		{"link", "outside/file", false},
+		{"link", "", false},
+	} {
+		err := CheckSymlink(root, filepath.FromSlash(c.name), c.target)
+		if c.safe && err != nil {
+			t.Errorf("%s -> %s: expect safe, but %v", c.name, c.target, err)
+		}
+		if !c.safe && !errors.Is(err, ErrUnsafeSymlink) {
+			t.Errorf("%s -> %s: expect ErrUnsafeSymlink, but %v", c.name, c.target, err)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package uncozip
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// The Info-ZIP Unicode Path extra field (0x7075) has the UTF-8 filename
+// with the CRC32 of the filename in the header. The UTF-8 name is used
+// only when the CRC32 matches because the other tools may have renamed the
+// entry without updating the extra field.
+
+const idUnicodePath = 0x7075
+
+// parseUnicodePath returns the UTF-8 filename in the data of the Unicode
+// Path extra field when it is for rawName.
+func parseUnicodePath(data, rawName []byte) (string, bool) {
+	if len(data) < 5 || data[0] != 1 {
+		return "", false
+	}
+	if binary.LittleEndian.Uint32(data[1:]) != crc32.ChecksumIEEE(rawName) {
+		return "", false
+	}
+	name := data[5:]
+	if len(name) == 0 || !utf8.Valid(name) {
+		return "", false
+	}
+	return strings.TrimLeft(string(name), "/"), true
+}
+
+func readUnicodePath(r io.Reader, cz *CorruptedZip) error {
+	if (cz.header.Bits & bitEncodedUTF8) != 0 {
+		cz.Debug("  Ignore: Unicode Path (the name is UTF-8 already)")
+		return nil
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	name, ok := parseUnicodePath(data, cz.rawName)
+	if !ok {
+		cz.Debug("  Ignore: Unicode Path (CRC32 mismatch or broken)")
+		return nil
+	}
+	cz.Debug("  Unicode Path:", name)
+	cz.name = name
+	cz.unicodePath = true
+	return nil
+}
+
+// findUnicodePath returns the UTF-8 filename in the extra fields of the
+// central directory header.
+func findUnicodePath(extra, rawName []byte) (string, bool) {
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra)
+		size := int(binary.LittleEndian.Uint16(extra[2:]))
+		extra = extra[4:]
+		if size > len(extra) {
+			break
+		}
+		if id == idUnicodePath {
+			return parseUnicodePath(extra[:size], rawName)
+		}
+		extra = extra[size:]
+	}
+	return "", false
+}
@@ -0,0 +1,76 @@
+package uncozip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"testing"
+)
+
+func unicodePathField(unicodeName string, rawName []byte) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, [2]uint16{idUnicodePath, uint16(5 + len(unicodeName))})
+	b.WriteByte(1)
+	binary.Write(&b, binary.LittleEndian, crc32.ChecksumIEEE(rawName))
+	b.WriteString(unicodeName)
+	return b.Bytes()
+}
+
+func TestUnicodePath(t *testing.T) {
+	const unicodeName = "日本語.txt"
+	rawName := []byte("\x93\xFA\x96\x7B\x8C\xEA.txt") // Shift_JIS
+	data := []byte("unicode")
+	sum := crc32.ChecksumIEEE(data)
+	errDecode := errors.New("unknown encoding")
+	decoder := func([]byte) (string, error) { return "", errDecode }
+
+	for _, c := range []struct {
+		extra  []byte
+		expect string
+		err    error
+	}{
+		{unicodePathField(unicodeName, rawName), unicodeName, nil},
+		{unicodePathField(unicodeName, []byte("renamed.txt")), "", errDecode},
+	} {
+		entry := makeEntry(string(rawName), Store, data, sum, uint32(len(data)), false)
+		cz := New(bytes.NewReader(insertExtraField(entry, len(rawName), c.extra)))
+		cz.RegisterNameDecoder(decoder)
+		if cz.Scan() != (c.err == nil) || !errors.Is(cz.Err(), c.err) {
+			t.Fatalf("expect %v, but %v", c.err, cz.Err())
+		}
+		if c.err == nil && cz.Name() != c.expect {
+			t.Fatalf("expect %s, but %s", c.expect, cz.Name())
+		}
+	}
+}
+
+func TestUnicodePathInCentralDirectory(t *testing.T) {
+	const unicodeName = "日本語.txt"
+	rawName := []byte("\x93\xFA\x96\x7B\x8C\xEA.txt")
+	extra := unicodePathField(unicodeName, rawName)
+
+	var archive bytes.Buffer
+	archive.Write(sigCentralDirectoryHeader)
+	binary.Write(&archive, binary.LittleEndian, &_CentralDirectoryHeader{
+		CreatorVersion:  hostUnix<<8 | 20,
+		FilenameLength:  uint16(len(rawName)),
+		ExtendFieldSize: uint16(len(extra)),
+	})
+	archive.Write(rawName)
+	archive.Write(extra)
+	archive.Write(sigEndOfCentralDirectory)
+
+	cz := New(bytes.NewReader(archive.Bytes()))
+	cz.RegisterNameDecoder(func(b []byte) (string, error) { return string(b), nil })
+	for range cz.Each {
+		t.Fatal("no entries expected")
+	}
+	entries, err := cz.ReadCentralDirectory()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(entries) != 1 || entries[0].Name != unicodeName {
+		t.Fatalf("expect %s, but %+v", unicodeName, entries)
+	}
+}
@@ -0,0 +1,194 @@
+package uncozip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+)
+
+// The local file header has no external attributes. The Unix mode of an
+// entry is found in the ASi Unix extra field (0x756e) of the local header or
+// in the external attributes of the central directory written by the Unix
+// hosts.
+
+const (
+	idASiUnix = 0x756e
+
+	hostUnix = 3
+	hostOSX  = 19
+
+	unixTypeMask  = 0o170000
+	unixDirectory = 0o040000
+	unixRegular   = 0o100000
+	unixSymlink   = 0o120000
+	unixSetuid    = 0o4000
+	unixSetgid    = 0o2000
+	unixSticky    = 0o1000
+)
+
+type _ASiUnix struct {
+	CRC32  uint32
+	Mode   uint16
+	SizDev uint32
+	UID    uint16
+	GID    uint16
+}
+
+func readASiUnixExtraField(r io.Reader, cz *CorruptedZip) error {
+	var header _ASiUnix
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return fmt.Errorf("ASi Unix Extra Field: %w", err)
+	}
+	mode := unixModeToFileMode(uint32(header.Mode))
+	cz.unixMode = &mode
+	cz.Debug("  ASi Unix Mode:", mode)
+	// The New Unix extra field (0x7875) is preferred for the wider IDs.
+	if cz.owner == nil {
+		cz.owner = &_UnixOwner{UID: int(header.UID), GID: int(header.GID)}
+	}
+	return nil
+}
+
+// unixModeToFileMode converts st_mode of Unix to fs.FileMode.
+func unixModeToFileMode(m uint32) fs.FileMode {
+	mode := fs.FileMode(m & 0o777)
+	switch m & unixTypeMask {
+	case unixDirectory:
+		mode |= fs.ModeDir
+	case unixSymlink:
+		mode |= fs.ModeSymlink
+	}
+	if (m & unixSetuid) != 0 {
+		mode |= fs.ModeSetuid
+	}
+	if (m & unixSetgid) != 0 {
+		mode |= fs.ModeSetgid
+	}
+	if (m & unixSticky) != 0 {
+		mode |= fs.ModeSticky
+	}
+	return mode
+}
+
+// fileModeToUnixMode converts fs.FileMode to st_mode of Unix.
+func fileModeToUnixMode(mode fs.FileMode) uint32 {
+	m := uint32(mode.Perm())
+	switch {
+	case mode.IsDir():
+		m |= unixDirectory
+	case (mode & fs.ModeSymlink) != 0:
+		m |= unixSymlink
+	default:
+		m |= unixRegular
+	}
+	if (mode & fs.ModeSetuid) != 0 {
+		m |= unixSetuid
+	}
+	if (mode & fs.ModeSetgid) != 0 {
+		m |= unixSetgid
+	}
+	if (mode & fs.ModeSticky) != 0 {
+		m |= unixSticky
+	}
+	return m
+}
+
+// Mode returns the Unix mode of the most recent file when the local header
+// has it in the extra field. Otherwise it returns false and the mode may be
+// found in the central directory with ReadCentralDirectory.
+func (cz *CorruptedZip) Mode() (fs.FileMode, bool) {
+	if cz.unixMode == nil {
+		return 0, false
+	}
+	return *cz.unixMode, true
+}
+
+type _UnixOwner struct {
+	UID int
+	GID int
+}
+
+// Owner returns the numeric user and group ID of the most recent file
+// stored in the Info-ZIP New Unix extra field (0x7875) or the ASi Unix
+// extra field (0x756e) of the local header.
+func (cz *CorruptedZip) Owner() (uid, gid int, ok bool) {
+	if cz.owner == nil {
+		return 0, 0, false
+	}
+	return cz.owner.UID, cz.owner.GID, true
+}
+
+// CentralDirectoryEntry is an entry of the central directory.
+type CentralDirectoryEntry struct {
+	Name              string
+	RawName           []byte
+	CreatorVersion    uint16
+	ExternalAttrs     uint32
+	LocalHeaderOffset uint32
+}
+
+// Mode returns the mode from the external attributes when the entry was
+// created on Unix or macOS and has the Unix mode.
+func (e *CentralDirectoryEntry) Mode() (fs.FileMode, bool) {
+	switch e.CreatorVersion >> 8 {
+	case hostUnix, hostOSX:
+		if m := e.ExternalAttrs >> 16; m != 0 {
+			return unixModeToFileMode(m), true
+		}
+	}
+	return 0, false
+}
+
+// ReadCentralDirectory reads the central directory following the last entry.
+// It has to be called after Scan returns false. When the central directory is
+// broken, the entries read before the broken one are returned with the error.
+func (cz *CorruptedZip) ReadCentralDirectory() ([]*CentralDirectoryEntry, error) {
+	if !cz.atCentralDirectory {
+		return nil, ErrCentralDirectoryNotFound
+	}
+	cz.atCentralDirectory = false
+	var entries []*CentralDirectoryEntry
+	for {
+		var h _CentralDirectoryHeader
+		if err := binary.Read(cz.br, binary.LittleEndian, &h); err != nil {
+			return entries, err
+		}
+		utf8 := (h.Bits & bitEncodedUTF8) != 0
+		name, rawName, nameErr := readFilenameField(cz.br, h.FilenameLength, utf8, cz.fnameDecoder)
+		if nameErr != nil && rawName == nil {
+			return entries, nameErr
+		}
+		extra := make([]byte, h.ExtendFieldSize)
+		if _, err := io.ReadFull(cz.br, extra); err != nil {
+			return entries, err
+		}
+		if unicodeName, ok := findUnicodePath(extra, rawName); ok && !utf8 {
+			name = unicodeName
+		} else if nameErr != nil {
+			return entries, nameErr
+		}
+		if _, err := io.CopyN(io.Discard, cz.br, int64(h.CommentLength)); err != nil {
+			return entries, err
+		}
+		entries = append(entries, &CentralDirectoryEntry{
+			Name:              name,
+			RawName:           rawName,
+			CreatorVersion:    h.CreatorVersion,
+			ExternalAttrs:     h.ExternalAttributes,
+			LocalHeaderOffset: h.LocalFileHeaderOffset,
+		})
+		var signature [4]byte
+		if _, err := io.ReadFull(cz.br, signature[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return entries, err
+		}
+		if !bytes.Equal(signature[:], sigCentralDirectoryHeader) {
+			return entries, nil
+		}
+	}
+}
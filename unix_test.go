@@ -0,0 +1,111 @@
+package uncozip
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"testing"
+)
+
+func TestReadCentralDirectory(t *testing.T) {
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	modes := map[string]fs.FileMode{
+		"run.sh":   0750,
+		"ro.txt":   0444,
+		"bin/":     fs.ModeDir | 0700,
+		"bin/tool": 0755 | fs.ModeSetuid,
+	}
+	for _, name := range []string{"run.sh", "ro.txt", "bin/", "bin/tool"} {
+		h := &zip.FileHeader{Name: name, Method: zip.Deflate}
+		h.SetMode(modes[name])
+		w, err := zw.CreateHeader(h)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		io.WriteString(w, name)
+	}
+	zw.Close()
+
+	cz := New(bytes.NewReader(archive.Bytes()))
+	if _, err := cz.ReadCentralDirectory(); !errors.Is(err, ErrCentralDirectoryNotFound) {
+		t.Fatalf("expect ErrCentralDirectoryNotFound before the entries are read, but %v", err)
+	}
+	for entry := range cz.Each {
+		if _, ok := entry.Mode(); ok {
+			t.Fatalf("%s: unexpected mode in the local header", entry.Name())
+		}
+		io.Copy(io.Discard, entry.Body())
+	}
+	if err := cz.Err(); err != nil {
+		t.Fatal(err.Error())
+	}
+	entries, err := cz.ReadCentralDirectory()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(entries) != len(modes) {
+		t.Fatalf("expect %d entries, but %d", len(modes), len(entries))
+	}
+	for _, e := range entries {
+		mode, ok := e.Mode()
+		if !ok {
+			t.Fatalf("%s: mode not found", e.Name)
+		}
+		if mode != modes[e.Name] {
+			t.Fatalf("%s: expect %v, but %v", e.Name, modes[e.Name], mode)
+		}
+	}
+}
+
+// insertExtraField inserts the extra field after the filename of the local
+// header made by makeEntry.
+func insertExtraField(entry []byte, nameLength int, extra []byte) []byte {
+	const headerSize = 30
+	nameEnd := headerSize + nameLength
+	binary.LittleEndian.PutUint16(entry[28:], uint16(len(extra)))
+	result := append([]byte{}, entry[:nameEnd]...)
+	result = append(result, extra...)
+	return append(result, entry[nameEnd:]...)
+}
+
+func TestASiUnixExtraField(t *testing.T) {
+	data := []byte("#!/bin/sh\n")
+	sum := crc32.ChecksumIEEE(data)
+	entry := makeEntry("run.sh", Store, data, sum, uint32(len(data)), false)
+
+	var extra bytes.Buffer
+	binary.Write(&extra, binary.LittleEndian, [2]uint16{idASiUnix, 14})
+	binary.Write(&extra, binary.LittleEndian, &_ASiUnix{Mode: unixRegular | 0755})
+
+	cz := New(bytes.NewReader(insertExtraField(entry, len("run.sh"), extra.Bytes())))
+	if !cz.Scan() {
+		t.Fatalf("Scan failed: %v", cz.Err())
+	}
+	mode, ok := cz.Mode()
+	if !ok || mode != 0755 {
+		t.Fatalf("expect 0755, but %v (%v)", mode, ok)
+	}
+}
+
+func TestOwner(t *testing.T) {
+	data := []byte("owner")
+	sum := crc32.ChecksumIEEE(data)
+	entry := makeEntry("owner.txt", Store, data, sum, uint32(len(data)), false)
+
+	// Version 1, the 4 bytes UID 70000 and the 2 bytes GID 1000
+	extra := []byte{0x75, 0x78, 9, 0, 1, 4, 0x70, 0x11, 0x01, 0x00, 2, 0xE8, 0x03}
+
+	cz := New(bytes.NewReader(insertExtraField(entry, len("owner.txt"), extra)))
+	if !cz.Scan() {
+		t.Fatalf("Scan failed: %v", cz.Err())
+	}
+	uid, gid, ok := cz.Owner()
+	if !ok || uid != 70000 || gid != 1000 {
+		t.Fatalf("expect 70000:1000, but %d:%d (%v)", uid, gid, ok)
+	}
+}